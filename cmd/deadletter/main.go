@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"product-management-system/config"
+	"product-management-system/internal/queue"
+	"time"
+)
+
+const connectTimeout = 10 * time.Second
+
+const usage = `Usage: go run ./cmd/deadletter <command> [-limit N]
+
+Commands:
+  list    print dead-lettered image jobs without removing them
+  replay  move dead-lettered image jobs back onto the work queue
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	limit := flags.Int("limit", 100, "maximum number of messages to handle")
+	flags.Parse(os.Args[2:])
+
+	// Load configurations
+	cfg := config.LoadConfig()
+
+	rabbitMQ := queue.NewRabbitMQ(cfg.RabbitMQ.Host, cfg.RabbitMQ.Port, cfg.RabbitMQ.QueueName, queue.RetryPolicy{
+		MaxRetries: cfg.RabbitMQ.MaxRetries,
+		BaseDelay:  cfg.RabbitMQ.RetryBaseDelay,
+	})
+	defer rabbitMQ.Close()
+
+	if err := rabbitMQ.WaitConnected(connectTimeout); err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+	}
+
+	switch command {
+	case "list":
+		letters, err := rabbitMQ.PeekDeadLetters(*limit)
+		if err != nil {
+			log.Fatalf("Failed to read dead-letter queue: %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(letters); err != nil {
+			log.Fatalf("Failed to print dead letters: %v", err)
+		}
+
+	case "replay":
+		replayed, err := rabbitMQ.ReplayDeadLetters(*limit)
+		if err != nil {
+			log.Fatalf("Replayed %d messages before failing: %v", replayed, err)
+		}
+		fmt.Printf("Replayed %d messages onto %s\n", replayed, cfg.RabbitMQ.QueueName)
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"product-management-system/config"
 	"product-management-system/internal/api"
 	"product-management-system/internal/cache"
+	"product-management-system/internal/migrations"
 	"product-management-system/internal/queue"
 	"product-management-system/internal/repository"
 	"product-management-system/internal/service"
+	"product-management-system/internal/storage"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -18,32 +27,66 @@ func main() {
 	cfg := config.LoadConfig()
 
 	// Initialize database connection
-	db := repository.InitPostgresDB(repository.DatabaseConfig{
-	Host:     cfg.Database.Host,
-	Port:     cfg.Database.Port,
-	User:     cfg.Database.User,
-	Password: cfg.Database.Password,
-	DBName:   cfg.Database.DBName,
-})
+	db := repository.InitPostgresDB(cfg.Database)
+
+	// The server does not migrate on its own; warn when the schema is behind the code or the config
+	warnPendingMigrations(db, migrations.Settings{SearchLanguage: cfg.Search.Language})
 
 	// Initialize Redis cache
 	redisCache := cache.NewRedisCache(cache.CacheConfig{
-	Host:     cfg.Redis.Host,
-	Port:     cfg.Redis.Port,
-	Password: cfg.Redis.Password,
-})
+		Host:     cfg.Redis.Host,
+		Port:     cfg.Redis.Port,
+		Password: cfg.Redis.Password,
+	})
 
 	// Initialize repositories
-	productRepo := repository.NewProductRepository(db)
+	productRepo := repository.NewProductRepository(db, cfg.Search.Language)
+	userRepo := repository.NewUserRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+
+	rabbitMQ := queue.NewRabbitMQ(cfg.RabbitMQ.Host, cfg.RabbitMQ.Port, cfg.RabbitMQ.QueueName, queue.RetryPolicy{
+		MaxRetries: cfg.RabbitMQ.MaxRetries,
+		BaseDelay:  cfg.RabbitMQ.RetryBaseDelay,
+	})
 
-	rabbitMQ := queue.NewRabbitMQ(cfg.RabbitMQ.Host, cfg.RabbitMQ.Port, cfg.RabbitMQ.QueueName)
+	// Initialize object storage for uploaded and processed images
+	objectStore, err := storage.New(cfg.Storage.Backend, cfg.Storage.Local, cfg.S3)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
 
 	// Initialize services
-	productService := service.NewProductService(*productRepo, *redisCache)
-	imageProcessor := service.NewImageProcessor(rabbitMQ)
+	productService := service.NewProductService(*productRepo, *redisCache, service.ProductCacheConfig{
+		TTL:         cfg.Redis.ProductTTL,
+		NegativeTTL: cfg.Redis.NegativeTTL,
+		FacetTTL:    cfg.Redis.FacetTTL,
+	}, objectStore)
+	userService := service.NewUserService(*userRepo)
+
+	// Token authentication is only configured when the auth mode accepts bearer tokens
+	var tokenService *service.TokenService
+	if cfg.Auth.Mode == api.AuthModeJWT || cfg.Auth.Mode == api.AuthModeBoth {
+		tokenService, err = service.NewTokenService(redisCache, service.TokenConfig{
+			Issuer:          cfg.Auth.Issuer,
+			ActiveKeyID:     cfg.Auth.ActiveKeyID,
+			Keys:            cfg.Auth.Keys,
+			AccessTokenTTL:  cfg.Auth.AccessTokenTTL,
+			RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize token service: %v", err)
+		}
+	}
+
+	imageProcessor := service.NewImageProcessor(rabbitMQ, productService, objectStore, cfg.ImageProcessing)
+	imageUploader := service.NewImageUploader(productService, objectStore, cfg.ImageUpload, cfg.ImageProcessing.PreserveCopyright)
 
 	// Start image processing queue consumer
-	go imageProcessor.ConsumeImageProcessingQueue()
+	imageProcessor.ConsumeImageProcessingQueue()
+
+	// Start relaying queued jobs from the outbox table to RabbitMQ
+	outboxRelay := service.NewOutboxRelay(*outboxRepo, rabbitMQ, cfg.Outbox)
+	outboxRelay.Start()
 
 	// Setup Gin router
 	router := gin.Default()
@@ -52,20 +95,116 @@ func main() {
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	// X-Forwarded-For is only believed from these proxies; with none, the client IP is the connection's peer
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	// Serve processed images when they are kept on local disk
+	if localStore, ok := objectStore.(*storage.LocalStorage); ok {
+		router.Static("/images", localStore.RootDir())
+	}
 
-	// Initialize product handler
-	productHandler := api.NewProductHandler(productService)
+	// Initialize handlers
+	productHandler := api.NewProductHandler(productService, imageUploader)
+	authHandler := api.NewAuthHandler(userService, tokenService)
+	healthHandler := api.NewHealthHandler(rabbitMQ)
+	authMiddleware := api.AuthMiddleware(userService, tokenService, cfg.Auth.Mode)
+	rateLimitMiddleware := api.RateLimitMiddleware(redisCache, cfg.RateLimit)
+	preAuthRateLimitMiddleware := api.PreAuthRateLimitMiddleware(redisCache, cfg.RateLimit)
 
 	// Define routes
+	router.GET("/health", healthHandler.Health)
+
 	v1 := router.Group("/api/v1")
+	auth := v1.Group("/auth", rateLimitMiddleware)
 	{
-		v1.POST("/products", productHandler.CreateProduct)
-		v1.GET("/products/:id", productHandler.GetProductByID)
-		v1.GET("/products", productHandler.ListProducts)
+		auth.POST("/register", authHandler.Register)
+		auth.POST("/login", authHandler.Login)
+		if tokenService != nil {
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", preAuthRateLimitMiddleware, authMiddleware, authHandler.Logout)
+		}
+	}
+	// Per-IP limiting runs before authentication so failed credentials are limited too;
+	// the route policies run after it so user-keyed policies see the caller
+	products := v1.Group("/products", preAuthRateLimitMiddleware, authMiddleware, rateLimitMiddleware)
+	{
+		products.POST("", productHandler.CreateProduct)
+		products.GET("/:id", productHandler.GetProductByID)
+		products.POST("/:id/images", productHandler.UploadImages)
+		products.GET("/:id/images/status", productHandler.GetImageStatus)
+		products.GET("/:id/similar-images", productHandler.GetSimilarImages)
+		products.GET("", productHandler.ListProducts)
+		products.PUT("/:id", productHandler.UpdateProduct)
+		products.PATCH("/:id", productHandler.PatchProduct)
+		products.DELETE("/:id", productHandler.DeleteProduct)
 	}
 
 	// Start server
-	if err := router.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for a termination signal, then drain HTTP requests and in-flight image jobs
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-signals.Done()
+	log.Println("Shutting down")
+
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	if err := outboxRelay.Shutdown(ctx); err != nil {
+		log.Printf("Outbox relay shutdown: %v", err)
+	}
+	if err := imageProcessor.Shutdown(ctx); err != nil {
+		log.Printf("Image processor shutdown: %v", err)
+	}
+	rabbitMQ.Close()
+	redisCache.Close()
+}
+
+// warnPendingMigrations logs migrations that have not been applied with cmd/migrate, and applied
+// migrations whose rendered script changed, such as 0007 after search.language was edited
+func warnPendingMigrations(db *gorm.DB, settings migrations.Settings) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("Failed to check migrations: %v", err)
+		return
+	}
+	migrator, err := migrations.NewMigrator(sqlDB, settings)
+	if err != nil {
+		log.Printf("Failed to check migrations: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Printf("Failed to check migrations: %v", err)
+		return
+	}
+	for _, status := range statuses {
+		switch {
+		case status.Modified:
+			log.Printf("Migration %04d_%s was applied from a different script or config; queries may not match the schema", status.Version, status.Name)
+		case !status.Applied:
+			log.Printf("Migration %04d_%s is not applied; run `go run ./cmd/migrate up`", status.Version, status.Name)
+		}
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"product-management-system/config"
+	"product-management-system/internal/migrations"
+	"product-management-system/internal/repository"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: go run ./cmd/migrate <command> [-steps N]
+
+Commands:
+  up      apply pending migrations (all of them unless -steps is set)
+  down    roll back the newest applied migrations (one unless -steps is set)
+          0001 and 0002 adopt pre-existing tables and cannot be rolled back
+  status  list migrations and whether they are applied
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	steps := flags.Int("steps", 0, "number of migrations to apply or roll back")
+	flags.Parse(os.Args[2:])
+
+	// Load configurations
+	cfg := config.LoadConfig()
+
+	db := repository.InitPostgresDB(cfg.Database)
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrations.NewMigrator(sqlDB, migrations.Settings{SearchLanguage: cfg.Search.Language})
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx, *steps)
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Modified {
+				state = "modified since applied"
+			}
+			if status.Missing {
+				state = "applied, not in this binary"
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		writer.Flush()
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// AuthConfig selects how clients authenticate and holds the token signing keys
+type AuthConfig struct {
+	Mode            string            `yaml:"mode"`
+	Issuer          string            `yaml:"issuer"`
+	ActiveKeyID     string            `yaml:"active_key_id"`
+	Keys            map[string]string `yaml:"keys"`
+	AccessTokenTTL  time.Duration     `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration     `yaml:"refresh_token_ttl"`
+}
+
+// applyEnv overrides signing keys from AUTH_ACTIVE_KEY_ID and AUTH_SIGNING_KEYS,
+// the latter a comma-separated list of id=secret pairs merged over the configured keys
+func (c *AuthConfig) applyEnv() error {
+	if id := os.Getenv("AUTH_ACTIVE_KEY_ID"); id != "" {
+		c.ActiveKeyID = id
+	}
+	keys := os.Getenv("AUTH_SIGNING_KEYS")
+	if keys == "" {
+		return nil
+	}
+	if c.Keys == nil {
+		c.Keys = make(map[string]string)
+	}
+	for _, pair := range strings.Split(keys, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" || secret == "" {
+			return fmt.Errorf("AUTH_SIGNING_KEYS entries must look like id=secret")
+		}
+		c.Keys[id] = secret
+	}
+	return nil
+}
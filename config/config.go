@@ -3,7 +3,7 @@ package config
 import (
 	"log"
 	"os"
-	"product-management-system/internal/repository"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,24 +11,38 @@ import (
 // Config represents the structure of the config file
 type Config struct {
 	Server struct {
-		Port  int  `yaml:"port"`
-		Debug bool `yaml:"debug"`
+		Port            int           `yaml:"port"`
+		Debug           bool          `yaml:"debug"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+		TrustedProxies  []string      `yaml:"trusted_proxies"`
 	} `yaml:"server"`
-	Database repository.DatabaseConfig `yaml:"database"`
-	Redis struct {
-		Host     string `yaml:"host"`
-		Port     int    `yaml:"port"`
-		Password string `yaml:"password"`
+	Database DatabaseConfig `yaml:"database"`
+	Redis    struct {
+		Host        string        `yaml:"host"`
+		Port        int           `yaml:"port"`
+		Password    string        `yaml:"password"`
+		ProductTTL  time.Duration `yaml:"product_ttl"`
+		NegativeTTL time.Duration `yaml:"negative_ttl"`
+		FacetTTL    time.Duration `yaml:"facet_ttl"`
 	} `yaml:"redis"`
 	RabbitMQ struct {
-		Host      string `yaml:"host"`
-		Port      int    `yaml:"port"`
-		QueueName string `yaml:"queue_name"`
+		Host           string        `yaml:"host"`
+		Port           int           `yaml:"port"`
+		QueueName      string        `yaml:"queue_name"`
+		MaxRetries     int           `yaml:"max_retries"`
+		RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
 	} `yaml:"rabbitmq"`
-	S3 struct {
-		Bucket string `yaml:"bucket"`
-		Region string `yaml:"region"`
-	} `yaml:"s3"`
+	Auth            AuthConfig            `yaml:"auth"`
+	RateLimit       RateLimitConfig       `yaml:"rate_limit"`
+	ImageProcessing ImageProcessingConfig `yaml:"image_processing"`
+	ImageUpload     ImageUploadConfig     `yaml:"image_upload"`
+	Outbox          OutboxConfig          `yaml:"outbox"`
+	Search          SearchConfig          `yaml:"search"`
+	Storage         struct {
+		Backend string      `yaml:"backend"`
+		Local   LocalConfig `yaml:"local"`
+	} `yaml:"storage"`
+	S3 S3Config `yaml:"s3"`
 }
 
 // LoadConfig loads configuration from config.yaml
@@ -44,6 +58,12 @@ func LoadConfig() *Config {
 	if err := decoder.Decode(&cfg); err != nil {
 		log.Fatalf("Error decoding config file: %v", err)
 	}
+	if err := cfg.Auth.applyEnv(); err != nil {
+		log.Fatalf("Error reading auth settings from the environment: %v", err)
+	}
+	if err := cfg.Search.normalize(); err != nil {
+		log.Fatalf("Invalid search config: %v", err)
+	}
 
 	return &cfg
 }
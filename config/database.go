@@ -0,0 +1,10 @@
+package config
+
+// DatabaseConfig holds the database configuration
+type DatabaseConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+}
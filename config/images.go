@@ -0,0 +1,40 @@
+package config
+
+import "time"
+
+// ImageVariantSpec describes one named rendition produced for every product image
+type ImageVariantSpec struct {
+	Name      string `yaml:"name"`
+	MaxWidth  int    `yaml:"max_width"`
+	MaxHeight int    `yaml:"max_height"`
+	Quality   int    `yaml:"quality"`
+}
+
+// ImageProcessingConfig controls how product images are downloaded, compressed and stored.
+// Quality is the default for variants that do not set their own. MaxWidth and MaxHeight
+// bound the "detail" variant used when no variants are configured.
+// AllowPrivateNetworks lifts the block on private, loopback and link-local hosts, for development only.
+// PreserveCopyright keeps the source's EXIF artist and copyright in the variants; all other metadata is always dropped.
+type ImageProcessingConfig struct {
+	Quality              int                `yaml:"quality"`
+	MaxWidth             int                `yaml:"max_width"`
+	MaxHeight            int                `yaml:"max_height"`
+	Variants             []ImageVariantSpec `yaml:"variants"`
+	DownloadTimeout      time.Duration      `yaml:"download_timeout"`
+	MaxDownloadBytes     int64              `yaml:"max_download_bytes"`
+	MaxSourcePixels      int64              `yaml:"max_source_pixels"`
+	AllowedSchemes       []string           `yaml:"allowed_schemes"`
+	MaxRedirects         int                `yaml:"max_redirects"`
+	AllowPrivateNetworks bool               `yaml:"allow_private_networks"`
+	PreserveCopyright    bool               `yaml:"preserve_copyright"`
+	Workers              int                `yaml:"workers"`
+	JobTimeout           time.Duration      `yaml:"job_timeout"`
+}
+
+// ImageUploadConfig limits direct image uploads
+type ImageUploadConfig struct {
+	MaxBytes  int64 `yaml:"max_bytes"`
+	MaxWidth  int   `yaml:"max_width"`
+	MaxHeight int   `yaml:"max_height"`
+	MaxFiles  int   `yaml:"max_files"`
+}
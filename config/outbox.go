@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// OutboxConfig controls how often pending outbox messages are relayed and how long sent ones are kept
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	Retention    time.Duration `yaml:"retention"`
+	// PublishTimeout bounds how long a batch waits for broker confirms while its rows are locked
+	PublishTimeout time.Duration `yaml:"publish_timeout"`
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// RateLimitPolicy limits requests to Requests per Period for each client key.
+// Method and Path select the route (Path is the gin route pattern, e.g. /api/v1/products/:id).
+type RateLimitPolicy struct {
+	Method   string        `yaml:"method"`
+	Path     string        `yaml:"path"`
+	Key      string        `yaml:"key"`
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+}
+
+// RateLimitConfig holds the default policy and per-route overrides.
+// PreAuth is an IP-keyed policy checked before authentication, so failed credential checks are limited too.
+type RateLimitConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	PreAuth RateLimitPolicy   `yaml:"pre_auth"`
+	Default RateLimitPolicy   `yaml:"default"`
+	Routes  []RateLimitPolicy `yaml:"routes"`
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultSearchLanguage is the text search configuration used when search.language is not set
+const DefaultSearchLanguage = "english"
+
+// searchLanguagePattern matches Postgres text search configuration names, which are rendered into migrations
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// SearchConfig controls full-text search over products.
+// Language is the Postgres text search configuration: migrations generate products.search_vector with it
+// and queries parse and highlight search terms with it, so the two always agree.
+type SearchConfig struct {
+	Language string `yaml:"language"`
+}
+
+// normalize fills in the default language and rejects names that are not plain identifiers
+func (c *SearchConfig) normalize() error {
+	if c.Language == "" {
+		c.Language = DefaultSearchLanguage
+	}
+	if !searchLanguagePattern.MatchString(c.Language) {
+		return fmt.Errorf("search.language %q is not a text search configuration name", c.Language)
+	}
+	return nil
+}
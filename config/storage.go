@@ -0,0 +1,20 @@
+package config
+
+// LocalConfig configures the local filesystem backend
+type LocalConfig struct {
+	RootDir       string `yaml:"root_dir"`
+	PublicBaseURL string `yaml:"public_base_url"`
+}
+
+// S3Config configures the S3-compatible backend.
+// Endpoint defaults to AWS; point it at a MinIO server for local development.
+type S3Config struct {
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	UseSSL          bool   `yaml:"use_ssl"`
+	PublicBaseURL   string `yaml:"public_base_url"`
+	CreateBucket    bool   `yaml:"create_bucket"`
+}
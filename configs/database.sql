@@ -1,16 +0,0 @@
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    username VARCHAR(255) UNIQUE NOT NULL,
-    email VARCHAR(255) UNIQUE NOT NULL
-);
-
-CREATE TABLE products (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER REFERENCES users(id),
-    product_name VARCHAR(255) NOT NULL,
-    product_description TEXT,
-    product_price DECIMAL(10, 2),
-    product_images TEXT[],
-    compressed_product_images TEXT[],
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
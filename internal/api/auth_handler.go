@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"product-management-system/internal/models"
+	"product-management-system/internal/service"
+	"product-management-system/pkg/logger"
+	"product-management-system/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler handles HTTP requests for user registration, login and token management
+type AuthHandler struct {
+	userService  *service.UserService
+	tokenService *service.TokenService
+}
+
+// NewAuthHandler creates a new instance of AuthHandler; ts may be nil when token authentication is disabled
+func NewAuthHandler(us *service.UserService, ts *service.TokenService) *AuthHandler {
+	return &AuthHandler{
+		userService:  us,
+		tokenService: ts,
+	}
+}
+
+// registerRequest is the body accepted by POST /auth/register
+type registerRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// loginRequest is the body accepted by POST /auth/login
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// refreshRequest is the body accepted by POST /auth/refresh and POST /auth/logout
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loginResponse carries the user and, when token authentication is enabled, a token pair
+type loginResponse struct {
+	User *models.User `json:"user"`
+	*service.TokenPair
+}
+
+// Register handles the POST /auth/register endpoint
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req registerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user := models.User{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: req.Password,
+	}
+	if err := utils.ValidateUserRegistration(user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.userService.RegisterUser(&user); err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Email already registered",
+			})
+			return
+		}
+		logger.Log.WithError(err).Error("Failed to register user")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Registration failed",
+		})
+		return
+	}
+
+	logger.Log.WithField("user_id", user.ID).Info("User registered successfully")
+	c.JSON(http.StatusCreated, user)
+}
+
+// Login handles the POST /auth/login endpoint
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.Authenticate(req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordNotSet) {
+			logger.Log.WithField("email", req.Email).Warn("Login attempt for a user without a password")
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid email or password",
+			})
+			return
+		}
+		logger.Log.WithError(err).Error("Failed to authenticate user")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Login failed",
+		})
+		return
+	}
+
+	response := loginResponse{User: user}
+	if h.tokenService != nil {
+		tokens, err := h.tokenService.IssueTokens(user)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to issue tokens")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Login failed",
+			})
+			return
+		}
+		response.TokenPair = tokens
+	}
+
+	logger.Log.WithField("user_id", user.ID).Info("User logged in")
+	c.JSON(http.StatusOK, response)
+}
+
+// Refresh handles the POST /auth/refresh endpoint, rotating the refresh token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": "refresh_token is required",
+		})
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid refresh token",
+			})
+			return
+		}
+		logger.Log.WithError(err).Error("Failed to refresh tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Token refresh failed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles the POST /auth/logout endpoint, revoking the current access token and the supplied refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req refreshRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// An already invalid refresh token needs no revocation, but another user's token may not be revoked
+	if req.RefreshToken != "" {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		err := h.tokenService.RevokeRefreshToken(req.RefreshToken, userID)
+		if errors.Is(err, service.ErrTokenNotOwned) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Refresh token belongs to another user",
+			})
+			return
+		}
+		if err != nil && !errors.Is(err, service.ErrInvalidToken) {
+			logger.Log.WithError(err).Error("Failed to revoke refresh token")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Logout failed",
+			})
+			return
+		}
+	}
+
+	if claims, ok := c.Get("token_claims"); ok {
+		if err := h.tokenService.RevokeAccessToken(claims.(*service.TokenClaims)); err != nil {
+			logger.Log.WithError(err).Error("Failed to revoke access token")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Logout failed",
+			})
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"product-management-system/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler reports the state of the service's external dependencies
+type HealthHandler struct {
+	rabbitMQ *queue.RabbitMQ
+}
+
+// NewHealthHandler creates a new instance of HealthHandler
+func NewHealthHandler(rabbitMQ *queue.RabbitMQ) *HealthHandler {
+	return &HealthHandler{
+		rabbitMQ: rabbitMQ,
+	}
+}
+
+// Health handles the GET /health endpoint, returning 503 while any dependency is unavailable
+func (h *HealthHandler) Health(c *gin.Context) {
+	rabbitMQState := h.rabbitMQ.State()
+
+	status, code := "ok", http.StatusOK
+	if rabbitMQState != queue.StateConnected {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"components": gin.H{
+			"rabbitmq": rabbitMQState,
+		},
+	})
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"product-management-system/config"
+	"product-management-system/internal/cache"
+	"product-management-system/internal/service"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/time/rate"
 )
 
 // LoggingMiddleware logs details about each incoming request
@@ -21,60 +28,208 @@ func LoggingMiddleware() gin.HandlerFunc {
 
 		// Log request details
 		duration := time.Since(start)
-		
+
 		logrus.WithFields(logrus.Fields{
-			"method":       c.Request.Method,
-			"path":         c.Request.URL.Path,
-			"status":       c.Writer.Status(),
-			"client_ip":    c.ClientIP(),
-			"latency":      duration.String(),
-			"user_agent":   c.Request.UserAgent(),
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"client_ip":  c.ClientIP(),
+			"latency":    duration.String(),
+			"user_agent": c.Request.UserAgent(),
 		}).Info("Incoming Request")
 	}
 }
 
-// AuthMiddleware handles basic authentication
-func AuthMiddleware() gin.HandlerFunc {
+// Authentication schemes accepted by AuthMiddleware
+const (
+	AuthModeBasic = "basic"
+	AuthModeJWT   = "jwt"
+	AuthModeBoth  = "both"
+)
+
+// AuthMiddleware authenticates requests with basic credentials, bearer access tokens, or either depending on mode
+func AuthMiddleware(userService *service.UserService, tokenService *service.TokenService, mode string) gin.HandlerFunc {
+	allowBasic := mode != AuthModeJWT
+	allowBearer := tokenService != nil && (mode == AuthModeJWT || mode == AuthModeBoth)
+
 	return func(c *gin.Context) {
-		
-		username, password, hasAuth := c.Request.BasicAuth()
-		
-		if !hasAuth || !validateCredentials(username, password) {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Unauthorized",
-			})
-			c.Abort()
+		header := c.GetHeader("Authorization")
+
+		switch {
+		case allowBearer && strings.HasPrefix(header, "Bearer "):
+			claims, err := tokenService.ValidateAccessToken(strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				if !errors.Is(err, service.ErrInvalidToken) {
+					logrus.WithError(err).Error("Failed to validate access token")
+				}
+				abortUnauthorized(c, allowBasic)
+				return
+			}
+			userID, err := claims.UserID()
+			if err != nil {
+				abortUnauthorized(c, allowBasic)
+				return
+			}
+
+			// Set user context for further use
+			c.Set("user_id", userID)
+			c.Set("token_claims", claims)
+
+		case allowBasic:
+			email, password, hasAuth := c.Request.BasicAuth()
+			if !hasAuth {
+				abortUnauthorized(c, allowBasic)
+				return
+			}
+
+			user, err := userService.Authenticate(email, password)
+			if err != nil {
+				if !errors.Is(err, service.ErrInvalidCredentials) {
+					logrus.WithError(err).Error("Failed to authenticate request")
+				}
+				abortUnauthorized(c, allowBasic)
+				return
+			}
+
+			// Set user context for further use
+			c.Set("user_id", user.ID)
+
+		default:
+			abortUnauthorized(c, allowBasic)
 			return
 		}
-		
-		// Set user context for further use
-		c.Set("user_id", getUserID(username))
+
 		c.Next()
 	}
 }
 
-// RateLimitMiddleware prevents too many requests from a single IP
-func RateLimitMiddleware() gin.HandlerFunc {
-	// Create a rate limiter that allows 100 requests per minute
-	limiter := rate.NewLimiter(rate.Limit(100), 100)
+// abortUnauthorized rejects the request, advertising the scheme clients should use
+func abortUnauthorized(c *gin.Context, allowBasic bool) {
+	if allowBasic {
+		c.Header("WWW-Authenticate", `Basic realm="api"`)
+	} else {
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Unauthorized",
+	})
+	c.Abort()
+}
+
+// Keys a rate limit policy can be applied per
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyUser = "user"
+)
+
+// RateLimitMiddleware enforces per-client limits shared across replicas through Redis.
+// It should run after AuthMiddleware on authenticated routes so user-keyed policies can see the user ID.
+func RateLimitMiddleware(redisCache *cache.RedisCache, cfg config.RateLimitConfig) gin.HandlerFunc {
+	routes := make(map[string]config.RateLimitPolicy, len(cfg.Routes))
+	for _, policy := range cfg.Routes {
+		routes[strings.ToUpper(policy.Method)+" "+policy.Path] = policy
+	}
+	for _, policy := range append(cfg.Routes, cfg.Default) {
+		if policy.Key != RateLimitKeyIP && policy.Key != RateLimitKeyUser {
+			logrus.WithField("key", policy.Key).Warn("Unknown rate limit key, limiting by client IP")
+		}
+	}
 
 	return func(c *gin.Context) {
-		// Get client IP
-		clientIP := c.ClientIP()
-
-		// Check if the request is allowed
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded",
-				"details": fmt.Sprintf("Too many requests from %s", clientIP),
-			})
-			c.Abort()
+		if !cfg.Enabled {
+			c.Next()
 			return
 		}
-		c.Next()
+
+		// Routes without their own policy share the default bucket
+		bucket := c.Request.Method + " " + c.FullPath()
+		policy, ok := routes[bucket]
+		if !ok {
+			bucket = "default"
+			policy = cfg.Default
+		}
+		if enforceRateLimit(c, redisCache, bucket, policy) {
+			c.Next()
+		}
 	}
 }
 
+// PreAuthRateLimitMiddleware enforces the pre_auth policy per client IP.
+// It runs before AuthMiddleware, which aborts bad credentials after an expensive bcrypt compare
+// and so would otherwise let password guessing bypass every later limit.
+func PreAuthRateLimitMiddleware(redisCache *cache.RedisCache, cfg config.RateLimitConfig) gin.HandlerFunc {
+	policy := cfg.PreAuth
+	policy.Key = RateLimitKeyIP
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		if enforceRateLimit(c, redisCache, "pre_auth", policy) {
+			c.Next()
+		}
+	}
+}
+
+// enforceRateLimit consumes a request from the policy's bucket and sets the rate limit headers.
+// It aborts with 429 and returns false when the client is over the limit.
+// RateLimit-Limit reports the burst, which is how many requests GCRA actually admits at once.
+func enforceRateLimit(c *gin.Context, redisCache *cache.RedisCache, bucket string, policy config.RateLimitPolicy) bool {
+	if policy.Requests <= 0 || policy.Period <= 0 {
+		return true
+	}
+
+	key := fmt.Sprintf("ratelimit:%s:%s", bucket, rateLimitClientKey(c, policy.Key))
+	result, err := redisCache.AllowRate(key, cache.RateLimit{
+		Requests: policy.Requests,
+		Period:   policy.Period,
+		Burst:    policy.Burst,
+	})
+	if err != nil {
+		// Fail open so a Redis outage does not take the API down with it
+		logrus.WithError(err).Warn("Rate limit check failed, allowing request")
+		return true
+	}
+
+	burst := policy.Burst
+	if burst <= 0 {
+		burst = policy.Requests
+	}
+	c.Header("RateLimit-Limit", strconv.Itoa(burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", policy.Requests, ceilSeconds(policy.Period), burst))
+
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":   "Rate limit exceeded",
+			"details": fmt.Sprintf("Too many requests, retry in %d seconds", ceilSeconds(result.RetryAfter)),
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// rateLimitClientKey identifies the caller according to the policy key, falling back to the client IP.
+// Only identities the server has verified are used, since any header the client picks freely would give it a fresh bucket per request.
+// ClientIP only honors X-Forwarded-For from the proxies trusted in server.trusted_proxies.
+func rateLimitClientKey(c *gin.Context, keyType string) string {
+	if keyType == RateLimitKeyUser {
+		if userID, ok := c.Get("user_id"); ok {
+			return fmt.Sprintf("user:%v", userID)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds a duration up to whole seconds for response headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
 // ErrorHandlingMiddleware handles panics and unexpected errors
 func ErrorHandlingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -82,10 +237,10 @@ func ErrorHandlingMiddleware() gin.HandlerFunc {
 			if err := recover(); err != nil {
 				// Log the error
 				logrus.WithFields(logrus.Fields{
-					"error":   err,
-					"method":  c.Request.Method,
-					"path":    c.Request.URL.Path,
-					"client":  c.ClientIP(),
+					"error":  err,
+					"method": c.Request.Method,
+					"path":   c.Request.URL.Path,
+					"client": c.ClientIP(),
 				}).Error("Panic recovered")
 
 				// Respond with internal server error
@@ -107,7 +262,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -117,17 +272,3 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// Helper functions (these would typically be in a separate authentication service)
-func validateCredentials(username, password string) bool {
-
-	return username == "admin" && password == "password"
-}
-
-func getUserID(username string) uint {
-	// Example: Return a dummy user ID
-	if username == "admin" {
-		return 1
-	}
-	return 0
-}
\ No newline at end of file
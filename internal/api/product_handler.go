@@ -1,11 +1,16 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"product-management-system/internal/models"
+	"product-management-system/internal/repository"
 	"product-management-system/internal/service"
 	"product-management-system/internal/shared"
 	"product-management-system/pkg/logger"
@@ -18,12 +23,14 @@ import (
 // ProductHandler handles HTTP requests related to products
 type ProductHandler struct {
 	productService *service.ProductService
+	imageUploader  *service.ImageUploader
 }
 
 // NewProductHandler creates a new instance of ProductHandler
-func NewProductHandler(ps *service.ProductService) *ProductHandler {
+func NewProductHandler(ps *service.ProductService, uploader *service.ImageUploader) *ProductHandler {
 	return &ProductHandler{
 		productService: ps,
+		imageUploader:  uploader,
 	}
 }
 
@@ -100,7 +107,8 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 	}
 
 	// Retrieve product with caching
-	product, err := h.productService.GetProductByID(uint(productID))
+	product, cacheHit, err := h.productService.GetProductByIDCached(uint(productID))
+	c.Header("X-Cache", cacheStatus(cacheHit))
 	if err != nil {
 		if err == service.ErrProductNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -120,49 +128,493 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 	duration := time.Since(start)
 	logger.Log.WithFields(logrus.Fields{
 		"product_id": product.ID,
+		"cache_hit":  cacheHit,
 		"duration":   duration,
 	}).Info("Product retrieved")
 
 	c.JSON(http.StatusOK, product)
 }
 
-// ListProducts handles the GET /products endpoint with filtering
+// listProductsQuery declares the parameters accepted by GET /products.
+// Pointers tell an absent parameter from an explicit zero. Struct tags cannot name constants, so the
+// limit and q bounds repeat service.MaxProductPageSize and shared.MaxSearchQueryLength, which
+// ProductService.ListProducts enforces for every caller.
+type listProductsQuery struct {
+	UserID       *uint    `form:"user_id" binding:"omitempty,min=1"`
+	MinPrice     *float64 `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice     *float64 `form:"max_price" binding:"omitempty,min=0"`
+	ProductName  string   `form:"product_name" binding:"max=255"`
+	Query        string   `form:"q" binding:"max=200"`
+	Category     string   `form:"category" binding:"max=100"`
+	Tag          string   `form:"tag" binding:"max=50"`
+	Sort         string   `form:"sort" binding:"omitempty,oneof=created_at price name relevance"`
+	Order        string   `form:"order" binding:"omitempty,oneof=asc desc"`
+	Limit        *int     `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset       *int     `form:"offset" binding:"omitempty,min=0"`
+	Cursor       string   `form:"cursor"`
+	IncludeTotal bool     `form:"include_total"`
+	// Facets is a comma-separated list of facet names
+	Facets string `form:"facets"`
+}
+
+// ListProducts handles the GET /products endpoint with filtering, sorting and pagination.
+// Pages continue either by offset or by passing back next_cursor; total is only counted on request.
 func (h *ProductHandler) ListProducts(c *gin.Context) {
 	start := time.Now()
 
-	// Parse query parameters
-	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
-	minPrice, _ := strconv.ParseFloat(c.Query("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.Query("max_price"), 64)
-	productName := c.Query("product_name")
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
-	// Create filter struct
-	filter := shared.ProductFilter{
-		UserID:       uint(userID),
-		MinPrice:     minPrice,
-		MaxPrice:     maxPrice,
-		ProductName:  productName,
+	var query listProductsQuery
+	if errs := bindQuery(c, &query); len(errs) > 0 {
+		respondWithFieldErrors(c, errs)
+		return
+	}
+	filter, errs := query.filter(userID)
+	if len(errs) > 0 {
+		respondWithFieldErrors(c, errs)
+		return
 	}
 
 	// Retrieve filtered products
-	products, err := h.productService.ListProducts(filter)
+	page, err := h.productService.ListProducts(filter)
+	if errs := filterFieldErrors(err); len(errs) > 0 {
+		respondWithFieldErrors(c, errs)
+		return
+	}
 	if err != nil {
-		logger.Log.WithError(err).Error("Failed to list products")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Product listing failed",
-			"details": err.Error(),
-		})
+		respondWithProductError(c, err, "Product listing failed")
 		return
 	}
 
 	// Log request processing
 	duration := time.Since(start)
 	logger.Log.WithFields(logrus.Fields{
-		"products_count": len(products),
+		"products_count": len(page.Products),
 		"duration":       duration,
 	}).Info("Products listed")
 
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, page)
+}
+
+// filter converts the parameters into a product filter; the service fills in defaults and checks
+// how sort, cursor, offset, q and facets fit together. Listings default to the caller's own products.
+// Without order, the default sorts (newest first, or best matches first for searches) are descending
+// and an explicitly chosen sort is ascending.
+func (q listProductsQuery) filter(callerID uint) (shared.ProductFilter, []FieldError) {
+	filter := shared.ProductFilter{
+		UserID:       callerID,
+		ProductName:  q.ProductName,
+		Query:        q.Query,
+		Category:     q.Category,
+		Tag:          q.Tag,
+		Sort:         shared.ProductSort(q.Sort),
+		Descending:   q.Order == "desc" || (q.Order == "" && (q.Sort == "" || q.Sort == string(shared.SortByRelevance))),
+		IncludeTotal: q.IncludeTotal,
+	}
+	if q.UserID != nil {
+		filter.UserID = *q.UserID
+	}
+	if q.MinPrice != nil {
+		filter.MinPrice = *q.MinPrice
+	}
+	if q.MaxPrice != nil {
+		filter.MaxPrice = *q.MaxPrice
+	}
+	if q.Limit != nil {
+		filter.Limit = *q.Limit
+	}
+	if q.Offset != nil {
+		filter.Offset = *q.Offset
+	}
+	for _, name := range strings.Split(q.Facets, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Facets = append(filter.Facets, shared.FacetName(name))
+		}
+	}
+
+	var errs []FieldError
+	// Zero means no bound in the filter, so only the parameters can tell an explicit min_price=0 apart
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		errs = append(errs, FieldError{Field: "max_price", Code: CodeInvalidRange, Message: "must not be less than min_price"})
+	}
+	if q.Cursor != "" {
+		cursor, err := shared.DecodeProductCursor(q.Cursor)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "cursor", Code: CodeInvalidCursor, Message: "must be a next_cursor returned by this endpoint"})
+		}
+		filter.After = cursor
+	}
+	return filter, errs
+}
+
+// filterProblemCodes maps the service's filter rejections onto FieldError codes
+var filterProblemCodes = map[service.FilterProblem]string{
+	service.FilterOutOfRange:    CodeOutOfRange,
+	service.FilterTooLong:       CodeTooLong,
+	service.FilterInvalidChoice: CodeInvalidChoice,
+	service.FilterInvalidCursor: CodeInvalidCursor,
+	service.FilterInvalidSearch: CodeInvalidSearch,
+	service.FilterConflict:      CodeConflict,
+}
+
+// filterFieldErrors lists the *service.FilterError rejections carried by err, which may join several
+func filterFieldErrors(err error) []FieldError {
+	causes := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		causes = joined.Unwrap()
+	}
+	var errs []FieldError
+	for _, cause := range causes {
+		var filterErr *service.FilterError
+		if errors.As(cause, &filterErr) {
+			errs = append(errs, FieldError{Field: filterErr.Field, Code: filterProblemCodes[filterErr.Problem], Message: filterErr.Message})
+		}
+	}
+	return errs
+}
+
+// imageStatusResponse is one entry of the GET /products/:id/images/status response
+type imageStatusResponse struct {
+	Index int `json:"index"`
+	models.ImageStatus
+	CompressedURL string               `json:"compressed_url,omitempty"`
+	Variants      models.ImageVariants `json:"variants,omitempty"`
+}
+
+// GetImageStatus handles the GET /products/:id/images/status endpoint
+func (h *ProductHandler) GetImageStatus(c *gin.Context) {
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	product, cacheHit, err := h.productService.GetProductByIDCached(productID)
+	c.Header("X-Cache", cacheStatus(cacheHit))
+	if err != nil {
+		respondWithProductError(c, err, "Image status retrieval failed")
+		return
+	}
+
+	statuses := product.AlignedImageStatus()
+	images := make([]imageStatusResponse, len(statuses))
+	for i, status := range statuses {
+		images[i] = imageStatusResponse{Index: i, ImageStatus: status}
+		if status.State != models.ImageStateSucceeded {
+			continue
+		}
+		if i < len(product.CompressedImages) {
+			images[i].CompressedURL = product.CompressedImages[i]
+		}
+		if i < len(product.ImageVariants) {
+			images[i].Variants = product.ImageVariants[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": product.ID,
+		"images":     images,
+	})
+}
+
+// UploadImages handles the POST /products/:id/images endpoint.
+// Files are sent as multipart form data in the "images" field.
+func (h *ProductHandler) UploadImages(c *gin.Context) {
+	start := time.Now()
+
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Cap the whole body so oversized requests are cut off before they are buffered to disk
+	limits := h.imageUploader.Config
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBytes*int64(limits.MaxFiles)+1<<20)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Upload too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	headers := form.File["images"]
+	if len(headers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": "at least one file is required in the images field",
+		})
+		return
+	}
+
+	files := make([]io.Reader, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to open uploaded file")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"details": err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+
+	product, err := h.imageUploader.UploadImages(c.Request.Context(), productID, userID, files)
+	if err != nil {
+		respondWithProductError(c, err, "Image upload failed")
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"product_id": product.ID,
+		"files":      len(files),
+		"duration":   time.Since(start),
+	}).Info("Product images uploaded")
+
+	// Processing continues asynchronously; image_status reports progress
+	c.JSON(http.StatusAccepted, product)
 }
 
+// Bounds for the GET /products/:id/similar-images query.
+// The default stays within the band indexes so that a plain call never misses a match.
+const (
+	defaultSimilarImageDistance = repository.MaxIndexedHashDistance
+	maxSimilarImageDistance     = 64
+	similarImageMatchLimit      = 20
+)
+
+// GetSimilarImages handles the GET /products/:id/similar-images endpoint for the product's owner.
+// max_distance is the largest Hamming distance between perceptual hashes that counts as similar.
+func (h *ProductHandler) GetSimilarImages(c *gin.Context) {
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	maxDistance := defaultSimilarImageDistance
+	if raw := c.Query("max_distance"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 || value > maxSimilarImageDistance {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"details": fmt.Sprintf("max_distance must be an integer between 0 and %d", maxSimilarImageDistance),
+			})
+			return
+		}
+		maxDistance = value
+	}
+
+	images, err := h.productService.SimilarImages(productID, userID, maxDistance, similarImageMatchLimit)
+	if err != nil {
+		respondWithProductError(c, err, "Similar image lookup failed")
+		return
+	}
 
+	complete := true
+	for _, image := range images {
+		complete = complete && image.Complete
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"product_id":   productID,
+		"max_distance": maxDistance,
+		"complete":     complete,
+		"images":       images,
+	})
+}
+
+// UpdateProduct handles the PUT /products/:id endpoint
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	start := time.Now()
+
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Bind JSON input to product model
+	var product models.Product
+	if err := c.ShouldBindJSON(&product); err != nil {
+		logger.Log.WithError(err).Error("Invalid product input")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updatedProduct, err := h.productService.UpdateProduct(productID, userID, &product)
+	if err != nil {
+		respondWithProductError(c, err, "Product update failed")
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"product_id": updatedProduct.ID,
+		"duration":   time.Since(start),
+	}).Info("Product updated successfully")
+
+	c.JSON(http.StatusOK, updatedProduct)
+}
+
+// PatchProduct handles the PATCH /products/:id endpoint using JSON merge-patch semantics
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	start := time.Now()
+
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil || len(patch) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": "request body must be a JSON merge patch",
+		})
+		return
+	}
+
+	patchedProduct, err := h.productService.PatchProduct(productID, userID, patch)
+	if err != nil {
+		respondWithProductError(c, err, "Product update failed")
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"product_id": patchedProduct.ID,
+		"duration":   time.Since(start),
+	}).Info("Product patched successfully")
+
+	c.JSON(http.StatusOK, patchedProduct)
+}
+
+// DeleteProduct handles the DELETE /products/:id endpoint
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	start := time.Now()
+
+	productID, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.productService.DeleteProduct(productID, userID); err != nil {
+		respondWithProductError(c, err, "Product deletion failed")
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"product_id": productID,
+		"duration":   time.Since(start),
+	}).Info("Product deleted successfully")
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseProductID reads the :id path parameter, responding with 400 when it is invalid
+func parseProductID(c *gin.Context) (uint, bool) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Log.WithError(err).Error("Invalid product ID")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid product ID",
+		})
+		return 0, false
+	}
+	return uint(productID), true
+}
+
+// currentUserID reads the user ID set by AuthMiddleware, responding with 401 when it is missing
+func currentUserID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get("user_id")
+	userID, ok := value.(uint)
+	if !exists || !ok {
+		logger.Log.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+// cacheStatus renders a cache lookup result for the X-Cache header
+func cacheStatus(hit bool) string {
+	if hit {
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// respondWithProductError maps product service errors to HTTP responses
+func respondWithProductError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Product not found",
+		})
+	case errors.Is(err, service.ErrProductForbidden):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Forbidden",
+		})
+	case errors.Is(err, service.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Image too large",
+			"details": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image",
+			"details": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidFilter):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidProduct):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	default:
+		logger.Log.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Codes reported in FieldError.Code
+const (
+	CodeUnknownParameter  = "unknown_parameter"
+	CodeRepeatedParameter = "repeated_parameter"
+	CodeInvalidInteger    = "invalid_integer"
+	CodeInvalidNumber     = "invalid_number"
+	CodeInvalidBoolean    = "invalid_boolean"
+	CodeOutOfRange        = "out_of_range"
+	CodeTooLong           = "too_long"
+	CodeInvalidChoice     = "invalid_choice"
+	CodeInvalidRange      = "invalid_range"
+	CodeInvalidCursor     = "invalid_cursor"
+	CodeInvalidSearch     = "invalid_search"
+	CodeConflict          = "conflict"
+)
+
+// FieldError describes why one query parameter was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// bindQuery decodes the query string into dst, a pointer to a struct whose fields carry `form` tags,
+// then checks its `binding` tags. Parameters without a matching field are rejected rather than ignored,
+// so a typo fails loudly instead of silently widening the result.
+// Supported field types are string, bool, ints, uints and floats, optionally behind a pointer to tell absent from zero.
+func bindQuery(c *gin.Context, dst interface{}) []FieldError {
+	value := reflect.ValueOf(dst).Elem()
+	fields := queryFields(value.Type())
+
+	var errs []FieldError
+	query := c.Request.URL.Query()
+	for _, name := range sortedKeys(query) {
+		index, ok := fields[name]
+		if !ok {
+			errs = append(errs, FieldError{Field: name, Code: CodeUnknownParameter, Message: "is not a supported parameter"})
+			continue
+		}
+		if len(query[name]) > 1 {
+			errs = append(errs, FieldError{Field: name, Code: CodeRepeatedParameter, Message: "must be given at most once"})
+			continue
+		}
+		if err := setQueryField(value.Field(index), query[name][0]); err != nil {
+			err.Field = name
+			errs = append(errs, *err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return []FieldError{{Code: CodeConflict, Message: err.Error()}}
+		}
+		for _, fieldErr := range validationErrs {
+			errs = append(errs, validationFieldError(value.Type(), fieldErr))
+		}
+	}
+	return errs
+}
+
+// respondWithFieldErrors answers 400 with the machine-readable list of rejected parameters
+func respondWithFieldErrors(c *gin.Context, errs []FieldError) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "Invalid query parameters",
+		"fields": errs,
+	})
+}
+
+// queryFields maps each form tag of t to its field index
+func queryFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("form"); name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// sortedKeys returns the parameter names in a stable order so error lists are reproducible
+func sortedKeys(query map[string][]string) []string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setQueryField parses raw into field according to its type
+func setQueryField(field reflect.Value, raw string) *FieldError {
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setQueryField(target.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return &FieldError{Code: CodeInvalidBoolean, Message: "must be true or false"}
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return &FieldError{Code: CodeInvalidInteger, Message: "must be an integer"}
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return &FieldError{Code: CodeInvalidInteger, Message: "must be a non-negative integer"}
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return &FieldError{Code: CodeInvalidNumber, Message: "must be a finite number"}
+		}
+		field.SetFloat(value)
+	default:
+		panic(fmt.Sprintf("bindQuery: unsupported field type %s", field.Type()))
+	}
+	return nil
+}
+
+// validationFieldError translates a failed binding tag into a FieldError named after the query parameter
+func validationFieldError(t reflect.Type, fieldErr validator.FieldError) FieldError {
+	name := fieldErr.StructField()
+	if field, ok := t.FieldByName(name); ok {
+		name = field.Tag.Get("form")
+	}
+
+	switch fieldErr.Tag() {
+	case "min":
+		return FieldError{Field: name, Code: CodeOutOfRange, Message: "must be at least " + fieldErr.Param()}
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return FieldError{Field: name, Code: CodeTooLong, Message: fmt.Sprintf("must be at most %s characters", fieldErr.Param())}
+		}
+		return FieldError{Field: name, Code: CodeOutOfRange, Message: "must be at most " + fieldErr.Param()}
+	case "oneof":
+		return FieldError{Field: name, Code: CodeInvalidChoice, Message: "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")}
+	default:
+		return FieldError{Field: name, Code: fieldErr.Tag(), Message: fmt.Sprintf("failed the %s check", fieldErr.Tag())}
+	}
+}
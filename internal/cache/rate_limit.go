@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// gcraScript implements the generic cell rate algorithm atomically in Redis.
+// The key stores the theoretical arrival time (TAT) of the next request, so
+// every replica sharing the Redis instance enforces the same limit.
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local emission_interval = period / rate
+local burst_offset = emission_interval * burst
+
+local now = redis.call("TIME")
+now = now[1] + (now[2] / 1000000)
+
+local tat = redis.call("GET", key)
+if not tat then
+	tat = now
+else
+	tat = tonumber(tat)
+end
+tat = math.max(tat, now)
+
+local new_tat = tat + emission_interval
+local diff = now - (new_tat - burst_offset)
+if diff < 0 then
+	return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", key, tostring(new_tat), "EX", math.ceil(reset_after))
+return {1, math.floor(diff / emission_interval), "0", tostring(reset_after)}
+`)
+
+// RateLimit describes how many requests are allowed per period, with burst extra capacity
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// RateLimitResult reports the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// AllowRate consumes one request from the bucket identified by key
+func (rc *RedisCache) AllowRate(key string, limit RateLimit) (*RateLimitResult, error) {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Requests
+	}
+
+	values, err := gcraScript.Run(rc.ctx, rc.client, []string{key},
+		burst, limit.Requests, limit.Period.Seconds()).Slice()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err,
+		}).Error("Failed to evaluate rate limit")
+		return nil, fmt.Errorf("failed to evaluate rate limit: %w", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retryAfter, err := parseSeconds(values[2])
+	if err != nil {
+		return nil, err
+	}
+	resetAfter, err := parseSeconds(values[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimitResult{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: retryAfter,
+		ResetAfter: resetAfter,
+	}, nil
+}
+
+// parseSeconds converts a fractional seconds string returned by Lua into a duration
+func parseSeconds(value interface{}) (time.Duration, error) {
+	text, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected rate limit value: %v", value)
+	}
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate limit value %q: %w", text, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -66,30 +66,94 @@ func (rc *RedisCache) Set(key string, value interface{}, expiration time.Duratio
 	return nil
 }
 
+// setIfGenerationScript stores KEYS[1] only while the counter at KEYS[2] still holds ARGV[2],
+// so a value read before a concurrent write cannot be cached after that write's invalidation
+var setIfGenerationScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[2]) or "0"
+if current ~= ARGV[2] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+return 1
+`)
+
+// Generation returns the value of a counter key, zero when it does not exist
+func (rc *RedisCache) Generation(key string) (int64, error) {
+	generation, err := rc.client.Get(rc.ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache generation: %w", err)
+	}
+	return generation, nil
+}
+
+// BumpGeneration increments a counter key, refreshing its expiration
+func (rc *RedisCache) BumpGeneration(key string, expiration time.Duration) error {
+	pipe := rc.client.TxPipeline()
+	pipe.Incr(rc.ctx, key)
+	pipe.PExpire(rc.ctx, key, expiration)
+	if _, err := pipe.Exec(rc.ctx); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err,
+		}).Error("Failed to bump cache generation")
+		return fmt.Errorf("failed to bump cache generation: %w", err)
+	}
+	return nil
+}
+
+// SetIfGeneration stores a value like Set, but only while the counter at generationKey still holds generation.
+// It reports whether the value was stored.
+func (rc *RedisCache) SetIfGeneration(key string, value interface{}, expiration time.Duration, generationKey string, generation int64) (bool, error) {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal cache value: %w", err)
+	}
+
+	stored, err := setIfGenerationScript.Run(rc.ctx, rc.client, []string{key, generationKey},
+		jsonData, generation, expiration.Milliseconds()).Int()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err,
+		}).Error("Failed to set cache value")
+		return false, fmt.Errorf("failed to set cache value: %w", err)
+	}
+	return stored == 1, nil
+}
+
 // Get retrieves a value from the cache
 func (rc *RedisCache) Get(key string, dest interface{}) error {
+	_, err := rc.Lookup(key, dest)
+	return err
+}
+
+// Lookup retrieves a value from the cache and reports whether the key was present
+func (rc *RedisCache) Lookup(key string, dest interface{}) (bool, error) {
 	// Retrieve from Redis
 	result, err := rc.client.Get(rc.ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			// Cache miss is not an error, just return nil
-			return nil
+			return false, nil
 		}
 		logrus.WithFields(logrus.Fields{
 			"key":   key,
 			"error": err,
 		}).Error("Failed to retrieve cache value")
-		return fmt.Errorf("failed to retrieve cache value: %w", err)
+		return false, fmt.Errorf("failed to retrieve cache value: %w", err)
 	}
 
 	// Unmarshal JSON
 	err = json.Unmarshal(result, dest)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to unmarshal cache value")
-		return fmt.Errorf("failed to unmarshal cache value: %w", err)
+		return false, fmt.Errorf("failed to unmarshal cache value: %w", err)
 	}
 
-	return nil
+	return true, nil
 }
 
 // Delete removes a key from the cache
@@ -107,6 +171,20 @@ func (rc *RedisCache) Delete(key string) error {
 	return nil
 }
 
+// Take removes a key and reports whether it existed, so only one caller can consume it
+func (rc *RedisCache) Take(key string) (bool, error) {
+	count, err := rc.client.Del(rc.ctx, key).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err,
+		}).Error("Failed to delete cache key")
+		return false, fmt.Errorf("failed to delete cache key: %w", err)
+	}
+
+	return count > 0, nil
+}
+
 // Exists checks if a key exists in the cache
 func (rc *RedisCache) Exists(key string) (bool, error) {
 	count, err := rc.client.Exists(rc.ctx, key).Result()
@@ -158,4 +236,4 @@ func (rc *RedisCache) Invalidate(pattern string) (int64, error) {
 // Close terminates the Redis connection
 func (rc *RedisCache) Close() error {
 	return rc.client.Close()
-}
\ No newline at end of file
+}
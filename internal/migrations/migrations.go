@@ -0,0 +1,378 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// advisoryLockID identifies the session-level Postgres advisory lock held while migrating.
+// It is arbitrary but must never change, or concurrent runs of old and new binaries would not exclude each other.
+const advisoryLockID = 720155001
+
+// fileName matches migration files such as 0003_add_product_image_processing.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrChecksumMismatch is returned when an applied migration's file was edited after it ran
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// ErrUnknownMigration is returned when the database has a migration this binary does not know about
+var ErrUnknownMigration = errors.New("unknown migration")
+
+// Migration is one versioned schema change with its reverse
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a migration known to the binary, the database, or both
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified marks an applied migration whose file no longer matches the recorded checksum
+	Modified bool `json:"modified,omitempty"`
+	// Missing marks an applied migration that is not embedded in this binary
+	Missing bool `json:"missing,omitempty"`
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Settings are configuration values substituted into the migration scripts.
+// The checksum covers the rendered scripts, so changing a setting after its migration
+// was applied is reported the same way as editing the file.
+type Settings struct {
+	// SearchLanguage replaces {{search_language}}, the text search configuration of products.search_vector
+	SearchLanguage string
+}
+
+// render substitutes the settings into a migration script
+func (s Settings) render(script string) string {
+	return strings.NewReplacer("{{search_language}}", s.SearchLanguage).Replace(script)
+}
+
+// Migrator applies and rolls back the embedded migrations
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the migrations embedded in the binary, rendered with settings
+func NewMigrator(db *sql.DB, settings Settings) (*Migrator, error) {
+	if settings.SearchLanguage == "" {
+		return nil, errors.New("migration settings need a search language")
+	}
+	migrations, err := load(files, settings)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies up to steps pending migrations in version order; steps <= 0 applies all of them.
+// Each migration runs in its own transaction together with its schema_migrations row.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back up to steps applied migrations, newest first; steps <= 0 rolls back one
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every embedded or applied migration in version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Status is read-only, so a database that was never migrated simply has nothing applied
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	done := map[int]appliedMigration{}
+	if exists {
+		if done, err = appliedMigrations(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := done[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.Checksum != migration.Checksum
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{
+			Version:   row.Version,
+			Name:      row.Name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending returns the embedded migrations that have not been applied
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(statuses))
+	for _, status := range statuses {
+		applied[status.Version] = status.Applied
+	}
+
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock.
+// The lock is session-scoped, so the connection is pinned rather than taken from the pool per statement.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// verify loads the applied migrations and refuses to continue if any were edited or are unknown
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	done, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+	for version, row := range done {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %04d_%s is applied but not embedded in this binary", ErrUnknownMigration, version, row.Name)
+		}
+		if migration.Checksum != row.Checksum {
+			return nil, fmt.Errorf("%w: %04d_%s was edited after it was applied", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+	return done, nil
+}
+
+// ensureTable creates the schema_migrations bookkeeping table
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations reads schema_migrations keyed by version
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int]appliedMigration{}
+	for rows.Next() {
+		var row appliedMigration
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		done[row.Version] = row
+	}
+	return done, rows.Err()
+}
+
+// inTx runs fn in a transaction on conn, rolling back on error
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checksum hashes both scripts of a migration, so editing either one is detected
+func checksum(up, down string) string {
+	hash := sha256.New()
+	hash.Write([]byte(up))
+	hash.Write([]byte{0})
+	hash.Write([]byte(down))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// load parses the migration files in fsys, pairing up and down scripts by version and rendering them with settings
+func load(fsys fs.FS, settings Settings) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has files with different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = settings.render(string(content))
+		} else {
+			migration.Down = settings.render(string(content))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migration.Checksum = checksum(migration.Up, migration.Down)
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
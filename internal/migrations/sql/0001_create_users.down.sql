@@ -0,0 +1,6 @@
+-- Irreversible: 0001 may have adopted a users table it did not create, so dropping it here could
+-- destroy existing data. Roll back by hand if the table really should go.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0001_create_users cannot be rolled back';
+END $$;
@@ -0,0 +1,25 @@
+-- Databases created from the old configs/database.sql already have a users table with a
+-- username column and no password; bring them in line with models.User instead of failing.
+-- The username column is kept so no data is lost, but it is no longer required, since
+-- registration does not set it. Dropping it would take a separate, later migration.
+-- Existing users get an empty password and cannot log in until one is set for them.
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL
+);
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'username'
+    ) THEN
+        ALTER TABLE users ALTER COLUMN username DROP NOT NULL;
+    END IF;
+END $$;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,6 @@
+-- Irreversible: 0002 may have adopted a products table it did not create, so dropping it here could
+-- destroy existing data. Roll back by hand if the table really should go.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0002_create_products cannot be rolled back';
+END $$;
@@ -0,0 +1,4 @@
+ALTER TABLE products
+    DROP COLUMN IF EXISTS image_placeholders,
+    DROP COLUMN IF EXISTS image_variants,
+    DROP COLUMN IF EXISTS image_status;
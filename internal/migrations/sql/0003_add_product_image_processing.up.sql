@@ -0,0 +1,5 @@
+-- Per-image processing results, each aligned with product_images
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS image_placeholders JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS image_variants JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS image_status JSONB NOT NULL DEFAULT '[]';
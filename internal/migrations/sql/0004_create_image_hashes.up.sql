@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS image_hashes (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    image_index INTEGER NOT NULL,
+    source_url TEXT NOT NULL,
+    hash BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, image_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_hashes_hash ON image_hashes (hash);
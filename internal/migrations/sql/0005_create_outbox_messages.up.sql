@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id SERIAL PRIMARY KEY,
+    message_id VARCHAR(64) NOT NULL UNIQUE,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ
+);
+
+-- The relay only ever scans unsent rows in id order
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages (id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_sent_at ON outbox_messages (sent_at);
@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_products_user_id ON products (user_id);
+
+DROP INDEX IF EXISTS idx_products_user_name;
+DROP INDEX IF EXISTS idx_products_user_price;
+DROP INDEX IF EXISTS idx_products_user_created_at;
+
+ALTER TABLE products ALTER COLUMN product_price DROP NOT NULL;
@@ -0,0 +1,11 @@
+-- Keyset pagination compares (sort column, id) as a row value, which never matches a NULL price
+UPDATE products SET product_price = 0 WHERE product_price IS NULL;
+ALTER TABLE products ALTER COLUMN product_price SET NOT NULL;
+
+-- Listings are always scoped to one seller, so each sort gets an index led by user_id
+CREATE INDEX IF NOT EXISTS idx_products_user_created_at ON products (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_products_user_price ON products (user_id, product_price, id);
+CREATE INDEX IF NOT EXISTS idx_products_user_name ON products (user_id, product_name, id);
+
+-- Covered by the indexes above
+DROP INDEX IF EXISTS idx_products_user_id;
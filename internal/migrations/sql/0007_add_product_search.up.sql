@@ -0,0 +1,9 @@
+-- The search document is maintained by Postgres itself, so every write path keeps it current.
+-- Names carry weight A and descriptions weight B, which ts_rank scores 1.0 and 0.4.
+-- The text search configuration is search.language from the config, which queries use as well.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('{{search_language}}'::regconfig, coalesce(product_name, '')), 'A') ||
+    setweight(to_tsvector('{{search_language}}'::regconfig, coalesce(product_description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector);
@@ -0,0 +1,6 @@
+-- Categories and tags let the storefront narrow listings and count the matches per value
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tags TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category);
+CREATE INDEX IF NOT EXISTS idx_products_tags ON products USING GIN (tags);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_image_hashes_band3;
+DROP INDEX IF EXISTS idx_image_hashes_band2;
+DROP INDEX IF EXISTS idx_image_hashes_band1;
+DROP INDEX IF EXISTS idx_image_hashes_band0;
+
+ALTER TABLE image_hashes
+    DROP COLUMN IF EXISTS band3,
+    DROP COLUMN IF EXISTS band2,
+    DROP COLUMN IF EXISTS band1,
+    DROP COLUMN IF EXISTS band0;
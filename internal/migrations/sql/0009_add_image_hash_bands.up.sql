@@ -0,0 +1,14 @@
+-- Each 64-bit hash is split into four 16-bit bands. Two hashes within 3 bits of each other differ
+-- in at most 3 bands, so they share at least one band exactly, and similarity lookups only need
+-- to compute Hamming distances for rows found through these indexes.
+-- The bands must match imageHashBands in the image hash repository.
+ALTER TABLE image_hashes
+    ADD COLUMN IF NOT EXISTS band0 INTEGER GENERATED ALWAYS AS ((hash >> 48) & 65535) STORED,
+    ADD COLUMN IF NOT EXISTS band1 INTEGER GENERATED ALWAYS AS ((hash >> 32) & 65535) STORED,
+    ADD COLUMN IF NOT EXISTS band2 INTEGER GENERATED ALWAYS AS ((hash >> 16) & 65535) STORED,
+    ADD COLUMN IF NOT EXISTS band3 INTEGER GENERATED ALWAYS AS (hash & 65535) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_image_hashes_band0 ON image_hashes (band0);
+CREATE INDEX IF NOT EXISTS idx_image_hashes_band1 ON image_hashes (band1);
+CREATE INDEX IF NOT EXISTS idx_image_hashes_band2 ON image_hashes (band2);
+CREATE INDEX IF NOT EXISTS idx_image_hashes_band3 ON image_hashes (band3);
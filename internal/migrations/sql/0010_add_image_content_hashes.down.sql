@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_image_hashes_updated_at;
+
+ALTER TABLE image_hashes DROP COLUMN IF EXISTS content_hash;
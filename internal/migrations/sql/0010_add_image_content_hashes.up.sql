@@ -0,0 +1,7 @@
+-- content_hash is the SHA-256 of an image's source bytes. Variants are only reused between images of a
+-- product with the same content hash; the perceptual hash is kept for similarity reports. Rows hashed
+-- before this migration have an empty content hash and are not reused until their image is processed again.
+ALTER TABLE image_hashes ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+
+-- Similarity searches wider than the band indexes cover examine the most recently hashed images
+CREATE INDEX IF NOT EXISTS idx_image_hashes_updated_at ON image_hashes (updated_at);
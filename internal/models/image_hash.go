@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ImageHash is the perceptual hash of one product image, used to spot similar pictures, and the SHA-256
+// of its source bytes, used to spot exact copies. Rows are keyed by image position, so SourceURL tells
+// whether the row still describes the current image.
+type ImageHash struct {
+	ProductID   uint      `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	ImageIndex  int       `gorm:"primaryKey;autoIncrement:false" json:"image_index"`
+	SourceURL   string    `json:"source_url"`
+	Hash        int64     `gorm:"index" json:"hash"`
+	ContentHash string    `json:"content_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ImageMatch is an image of another product whose hash is within some Hamming distance of a reference hash
+type ImageMatch struct {
+	ProductID  uint   `json:"product_id"`
+	ImageIndex int    `json:"image_index"`
+	SourceURL  string `json:"source_url"`
+	Distance   int    `json:"distance"`
+}
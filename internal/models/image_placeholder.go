@@ -0,0 +1,44 @@
+package models
+
+import "database/sql/driver"
+
+// ImagePlaceholder is a compact stand-in shown while a product image loads
+type ImagePlaceholder struct {
+	Blurhash      string `json:"blurhash,omitempty"`
+	DominantColor string `json:"dominant_color,omitempty"`
+}
+
+// ImagePlaceholderList maps per-image placeholders to a Postgres jsonb column.
+// Entries are positionally aligned with Product.ProductImages; an image not yet processed has a zero entry.
+type ImagePlaceholderList []ImagePlaceholder
+
+// Scan implements sql.Scanner
+func (l *ImagePlaceholderList) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+
+	var placeholders []ImagePlaceholder
+	if err := scanJSON(src, &placeholders); err != nil {
+		return err
+	}
+	*l = placeholders
+	return nil
+}
+
+// Value implements driver.Valuer
+func (l ImagePlaceholderList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return jsonValue([]ImagePlaceholder(l))
+}
+
+// ProcessedImage is everything the image worker derives from one source image
+type ProcessedImage struct {
+	Variants    ImageVariants
+	Hash        int64
+	ContentHash string
+	Placeholder ImagePlaceholder
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// ImageState is the processing state of a single product image
+type ImageState string
+
+// Image processing states
+const (
+	ImageStateQueued     ImageState = "queued"
+	ImageStateProcessing ImageState = "processing"
+	ImageStateSucceeded  ImageState = "succeeded"
+	ImageStateFailed     ImageState = "failed"
+)
+
+// ImageStatus tracks the processing of one product image.
+// Error holds the most recent failure, including one that is waiting for a retry.
+type ImageStatus struct {
+	SourceURL   string     `json:"source_url"`
+	State       ImageState `json:"state"`
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error,omitempty"`
+	QueuedAt    *time.Time `json:"queued_at,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// ImageStatusList maps per-image statuses to a Postgres jsonb column.
+// Entries are positionally aligned with Product.ProductImages.
+type ImageStatusList []ImageStatus
+
+// NewQueuedImageStatuses returns a queued status for every image URL
+func NewQueuedImageStatuses(imageURLs []string, queuedAt time.Time) ImageStatusList {
+	statuses := make(ImageStatusList, len(imageURLs))
+	for i, imageURL := range imageURLs {
+		statuses[i] = ImageStatus{
+			SourceURL: imageURL,
+			State:     ImageStateQueued,
+			QueuedAt:  &queuedAt,
+			UpdatedAt: &queuedAt,
+		}
+	}
+	return statuses
+}
+
+// Scan implements sql.Scanner
+func (l *ImageStatusList) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+
+	var statuses []ImageStatus
+	if err := scanJSON(src, &statuses); err != nil {
+		return err
+	}
+	*l = statuses
+	return nil
+}
+
+// Value implements driver.Valuer
+func (l ImageStatusList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return jsonValue([]ImageStatus(l))
+}
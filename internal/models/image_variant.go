@@ -0,0 +1,60 @@
+package models
+
+import "database/sql/driver"
+
+// OriginalVariant is the variant name describing the uploaded source image
+const OriginalVariant = "original"
+
+// ImageVariant is one stored rendition of a product image
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int64  `json:"bytes"`
+	Format string `json:"format"`
+}
+
+// ImageVariants holds the renditions of one image keyed by variant name, e.g. "thumb" or "card"
+type ImageVariants map[string]ImageVariant
+
+// ImageVariantsList maps per-image renditions to a Postgres jsonb column.
+// Entries are positionally aligned with Product.ProductImages; an image not yet processed has a nil entry.
+type ImageVariantsList []ImageVariants
+
+// Scan implements sql.Scanner
+func (l *ImageVariantsList) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+
+	var variants []ImageVariants
+	if err := scanJSON(src, &variants); err != nil {
+		return err
+	}
+	*l = variants
+	return nil
+}
+
+// Value implements driver.Valuer
+func (l ImageVariantsList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return jsonValue([]ImageVariants(l))
+}
+
+// Largest returns the processed variant with the most pixels, ignoring the original
+func (v ImageVariants) Largest() (ImageVariant, bool) {
+	var largest ImageVariant
+	found := false
+	for name, variant := range v {
+		if name == OriginalVariant {
+			continue
+		}
+		if !found || variant.Width*variant.Height > largest.Width*largest.Height {
+			largest, found = variant, true
+		}
+	}
+	return largest, found
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// scanJSON decodes a jsonb column value into dest
+func scanJSON(src interface{}, dest interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, dest)
+	case string:
+		return json.Unmarshal([]byte(value), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+}
+
+// jsonValue encodes v for a jsonb column
+func jsonValue(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
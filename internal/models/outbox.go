@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxMessage is a queue message written in the same transaction as the change that produced it.
+// The outbox relay publishes pending rows and stamps SentAt once the broker confirms them.
+type OutboxMessage struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	MessageID string     `gorm:"uniqueIndex" json:"message_id"`
+	Payload   []byte     `gorm:"type:jsonb" json:"payload"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `gorm:"index" json:"sent_at"`
+}
@@ -1,11 +1,85 @@
 package models
 
+import (
+	"sort"
+	"time"
+)
+
 type Product struct {
-	ID                 uint     `gorm:"primaryKey" json:"id"`
-	UserID             uint     `json:"user_id"`
-	ProductName        string   `json:"product_name"`
-	ProductDescription string   `json:"product_description"`
-	ProductImages      []string `gorm:"type:text[]" json:"product_images"`
-	CompressedImages   []string `gorm:"type:text[]" json:"compressed_product_images"`
-	ProductPrice       float64  `json:"product_price"`
+	ID                 uint                 `gorm:"primaryKey" json:"id"`
+	UserID             uint                 `json:"user_id"`
+	ProductName        string               `json:"product_name"`
+	ProductDescription string               `json:"product_description"`
+	ProductImages      StringArray          `gorm:"type:text[]" json:"product_images"`
+	CompressedImages   StringArray          `gorm:"type:text[]" json:"compressed_product_images"`
+	ImagePlaceholders  ImagePlaceholderList `gorm:"type:jsonb" json:"image_placeholders"`
+	ImageVariants      ImageVariantsList    `gorm:"type:jsonb" json:"image_variants"`
+	ImageStatus        ImageStatusList      `gorm:"type:jsonb" json:"image_status"`
+	ProductPrice       float64              `json:"product_price"`
+	Category           string               `json:"category"`
+	Tags               StringArray          `gorm:"type:text[]" json:"tags"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+// AlignedImageStatus returns one status per product image.
+// Rows written before status tracking have no entries, so their state is inferred from the compressed images.
+func (p *Product) AlignedImageStatus() ImageStatusList {
+	statuses := make(ImageStatusList, len(p.ProductImages))
+	for i, imageURL := range p.ProductImages {
+		if i < len(p.ImageStatus) && p.ImageStatus[i].SourceURL == imageURL {
+			statuses[i] = p.ImageStatus[i]
+			continue
+		}
+
+		statuses[i] = ImageStatus{SourceURL: imageURL, State: ImageStateQueued}
+		if i < len(p.CompressedImages) && p.CompressedImages[i] != "" {
+			statuses[i].State = ImageStateSucceeded
+		}
+	}
+	return statuses
+}
+
+// ReferencedURLs returns every URL the product points at: source images, compressed images and variants
+func (p *Product) ReferencedURLs() map[string]bool {
+	urls := make(map[string]bool, len(p.ProductImages)*2)
+	for _, url := range p.ProductImages {
+		urls[url] = true
+	}
+	for _, url := range p.CompressedImages {
+		urls[url] = true
+	}
+	for _, variants := range p.ImageVariants {
+		for _, variant := range variants {
+			urls[variant.URL] = true
+		}
+	}
+	delete(urls, "")
+	return urls
+}
+
+// ReleasedURLs lists, in sorted order, the URLs in before that after no longer references.
+// A nil after releases everything, as when the product is deleted.
+func ReleasedURLs(before map[string]bool, after *Product) []string {
+	var kept map[string]bool
+	if after != nil {
+		kept = after.ReferencedURLs()
+	}
+	var released []string
+	for url := range before {
+		if !kept[url] {
+			released = append(released, url)
+		}
+	}
+	sort.Strings(released)
+	return released
+}
+
+// AlignedPlaceholders returns one placeholder per product image.
+// Rows written before placeholders existed, or with images appended since, have shorter lists;
+// the missing entries are zero placeholders.
+func (p *Product) AlignedPlaceholders() ImagePlaceholderList {
+	placeholders := make(ImagePlaceholderList, len(p.ProductImages))
+	copy(placeholders, p.ImagePlaceholders)
+	return placeholders
 }
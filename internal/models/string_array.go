@@ -0,0 +1,34 @@
+package models
+
+import (
+	"database/sql/driver"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StringArray maps a string slice to a Postgres text[] column.
+// The pgx database/sql driver returns arrays as their text literal, so scanning needs pgtype's help.
+type StringArray []string
+
+// Scan implements sql.Scanner
+func (a *StringArray) Scan(src interface{}) error {
+	if src == nil {
+		*a = nil
+		return nil
+	}
+
+	var values []string
+	if err := pgtype.NewMap().SQLScanner(&values).Scan(src); err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// Value implements driver.Valuer
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return []string(a), nil
+}
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetter describes a message parked in the dead-letter queue
+type DeadLetter struct {
+	MessageID  string    `json:"message_id"`
+	Body       string    `json:"body"`
+	RetryCount int       `json:"retry_count"`
+	LastError  string    `json:"last_error"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// PeekDeadLetters returns up to limit dead-lettered messages without removing them
+func (r *RabbitMQ) PeekDeadLetters(limit int) ([]DeadLetter, error) {
+	conn, err := r.currentConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	// A dedicated channel keeps the multiple-nack below from touching the consumer's deliveries
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	var letters []DeadLetter
+	var lastTag uint64
+	for len(letters) < limit {
+		msg, ok, err := ch.Get(r.DeadLetterQueueName(), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		lastTag = msg.DeliveryTag
+		letters = append(letters, toDeadLetter(msg))
+	}
+
+	// Return everything that was fetched to the queue
+	if lastTag > 0 {
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, err
+		}
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit dead-lettered messages back onto the work queue with a fresh retry budget
+func (r *RabbitMQ) ReplayDeadLetters(limit int) (int, error) {
+	conn, err := r.currentConnection()
+	if err != nil {
+		return 0, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := ch.Get(r.DeadLetterQueueName(), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		delete(headers, headerRetryCount)
+
+		err = ch.Publish("", r.QueueName, false, false, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    msg.MessageId,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			msg.Nack(false, true)
+			return replayed, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// toDeadLetter extracts the failure details recorded in a dead-lettered message
+func toDeadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID:  msg.MessageId,
+		Body:       string(msg.Body),
+		RetryCount: retryCount(msg.Headers),
+	}
+	if lastError, ok := msg.Headers[headerLastError].(string); ok {
+		letter.LastError = lastError
+	}
+	if failedAt, ok := msg.Headers[headerFailedAt].(string); ok {
+		letter.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+	}
+	return letter
+}
@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Message headers used to track retries
+const (
+	headerRetryCount = "x-retry-count"
+	headerLastError  = "x-last-error"
+	headerFailedAt   = "x-failed-at"
+)
+
+// Delivery is a message received from the work queue that must be settled exactly once
+type Delivery struct {
+	delivery amqp.Delivery
+	channel  *amqp.Channel
+	queue    *RabbitMQ
+}
+
+// Body returns the message payload
+func (d Delivery) Body() []byte {
+	return d.delivery.Body
+}
+
+// RetryCount returns how many times the message has already been retried
+func (d Delivery) RetryCount() int {
+	return retryCount(d.delivery.Headers)
+}
+
+// FinalAttempt reports whether a failure now would send the message to the dead-letter queue
+func (d Delivery) FinalAttempt() bool {
+	return d.RetryCount()+1 > d.queue.RetryPolicy.MaxRetries
+}
+
+// Ack marks the message as successfully processed
+func (d Delivery) Ack() error {
+	return d.delivery.Ack(false)
+}
+
+// Retry schedules the message for another attempt after a backoff delay,
+// or moves it to the dead-letter queue once the retry limit is reached.
+// The republish happens before the ack, so a crash in between duplicates rather than loses the message.
+func (d Delivery) Retry(cause error) error {
+	if d.FinalAttempt() {
+		return d.DeadLetter(cause)
+	}
+	attempt := d.RetryCount() + 1
+
+	if err := d.republish(d.queue.retryQueueName(attempt), attempt, cause); err != nil {
+		// Leave the message with the broker rather than dropping it
+		d.delivery.Nack(false, true)
+		return err
+	}
+	return d.delivery.Ack(false)
+}
+
+// DeadLetter moves the message straight to the dead-letter queue, for failures retrying cannot fix
+func (d Delivery) DeadLetter(cause error) error {
+	if err := d.republish(d.queue.DeadLetterQueueName(), d.RetryCount(), cause); err != nil {
+		d.delivery.Nack(false, true)
+		return err
+	}
+	return d.delivery.Ack(false)
+}
+
+// republish copies the message to another queue with updated retry headers
+func (d Delivery) republish(queueName string, attempt int, cause error) error {
+	headers := amqp.Table{}
+	for key, value := range d.delivery.Headers {
+		headers[key] = value
+	}
+	headers[headerRetryCount] = int32(attempt)
+	headers[headerFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	if cause != nil {
+		headers[headerLastError] = cause.Error()
+	}
+
+	// Republish on the channel the message arrived on; if that session is gone the broker redelivers anyway
+	return d.channel.Publish(
+		"",        // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  d.delivery.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    d.delivery.MessageId,
+			Body:         d.delivery.Body,
+		},
+	)
+}
+
+// retryCount reads the retry counter from message headers
+func retryCount(headers amqp.Table) int {
+	switch value := headers[headerRetryCount].(type) {
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
+	}
+}
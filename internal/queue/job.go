@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// JobSchemaVersion is the envelope version written by this build.
+// Bump it when a change would break workers that are still running the previous version.
+const JobSchemaVersion = 1
+
+// Job types understood by the image processor
+const (
+	JobTypeProcessImage   = "image.process"
+	JobTypeReprocessImage = "image.reprocess"
+)
+
+// ErrMalformedJob is returned for payloads that can never be processed and belong in the dead-letter queue
+var ErrMalformedJob = errors.New("malformed job")
+
+// Job is the versioned envelope for every message on the work queue
+type Job struct {
+	SchemaVersion int       `json:"schema_version"`
+	JobID         string    `json:"job_id"`
+	Type          string    `json:"type"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	ProductID     uint      `json:"product_id"`
+	ImageIndex    int       `json:"image_index"`
+	ImageURL      string    `json:"image_url"`
+	Variants      []string  `json:"variants,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// EncodeJob validates a job and serializes it, stamping the current schema version
+func EncodeJob(job Job) ([]byte, error) {
+	job.SchemaVersion = JobSchemaVersion
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now().UTC()
+	}
+	if err := validateJob(job); err != nil {
+		return nil, err
+	}
+	return json.Marshal(job)
+}
+
+// DecodeJob parses and validates a job envelope. Every error wraps ErrMalformedJob.
+func DecodeJob(body []byte) (Job, error) {
+	var job Job
+	if err := json.Unmarshal(body, &job); err != nil {
+		return job, fmt.Errorf("%w: %v", ErrMalformedJob, err)
+	}
+
+	// Messages queued before the envelope was versioned only ever meant "process this image"
+	if job.SchemaVersion == 0 && job.Type == "" {
+		job.SchemaVersion = 1
+		job.Type = JobTypeProcessImage
+	}
+
+	if job.SchemaVersion < 1 || job.SchemaVersion > JobSchemaVersion {
+		return job, fmt.Errorf("%w: unsupported schema version %d", ErrMalformedJob, job.SchemaVersion)
+	}
+	if err := validateJob(job); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// validateJob checks the fields every job type relies on
+func validateJob(job Job) error {
+	if job.JobID == "" {
+		return fmt.Errorf("%w: job_id is required", ErrMalformedJob)
+	}
+	if job.Type == "" {
+		return fmt.Errorf("%w: type is required", ErrMalformedJob)
+	}
+	if job.ProductID == 0 {
+		return fmt.Errorf("%w: product_id is required", ErrMalformedJob)
+	}
+	if job.ImageIndex < 0 {
+		return fmt.Errorf("%w: image_index must not be negative", ErrMalformedJob)
+	}
+	if job.ImageURL == "" {
+		return fmt.Errorf("%w: image_url is required", ErrMalformedJob)
+	}
+	return nil
+}
+
+// PublishJobsConfirmed publishes jobs on a dedicated confirm-mode channel and waits for the broker to
+// acknowledge each one. The returned slice reports, per job, whether the broker took responsibility for it.
+func (r *RabbitMQ) PublishJobsConfirmed(ctx context.Context, jobs []Job) ([]bool, error) {
+	conn, err := r.currentConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	// Confirms count every publish on a channel, so this must not share the consumer's channel
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, len(jobs)))
+
+	for _, job := range jobs {
+		msg, err := jobPublishing(job)
+		if err != nil {
+			return nil, err
+		}
+		if err := ch.Publish("", r.QueueName, false, false, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Confirmations arrive in publish order
+	acked := make([]bool, len(jobs))
+	for i := range jobs {
+		select {
+		case confirm, ok := <-confirms:
+			if !ok {
+				return nil, ErrNotConnected
+			}
+			acked[i] = confirm.Ack
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return acked, nil
+}
+
+// jobPublishing encodes a job into an AMQP message with its metadata in the message properties
+func jobPublishing(job Job) (amqp.Publishing, error) {
+	body, err := EncodeJob(job)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     job.JobID,
+		CorrelationId: job.CorrelationID,
+		Type:          job.Type,
+		Timestamp:     time.Now().UTC(),
+		Body:          body,
+	}, nil
+}
@@ -1,92 +1,390 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
-// RabbitMQ represents the RabbitMQ connection and channel
+const (
+	defaultMaxRetries     = 5
+	defaultRetryBaseDelay = 5 * time.Second
+)
+
+// RetryPolicy controls how failed messages are retried before being dead-lettered.
+// Attempt n waits BaseDelay * 2^(n-1) in its own delay queue before returning to the main queue.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+// Connection states reported by State
+const (
+	StateConnecting = "connecting"
+	StateConnected  = "connected"
+	StateClosed     = "closed"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ErrNotConnected is returned by publishes attempted while the broker is unreachable
+var ErrNotConnected = errors.New("rabbitmq is not connected")
+
+// ErrClosed is returned once Close has been called
+var ErrClosed = errors.New("rabbitmq connection manager is closed")
+
+// RabbitMQ manages a RabbitMQ connection and channel, reconnecting with backoff whenever the broker goes away
 type RabbitMQ struct {
-	Connection *amqp.Connection
-	Channel    *amqp.Channel
-	QueueName  string
+	QueueName   string
+	RetryPolicy RetryPolicy
+
+	url        string
+	mu         sync.RWMutex
+	connection *amqp.Connection
+	channel    *amqp.Channel
+	state      string
+	ready      chan struct{} // closed while a session is established
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewRabbitMQ initializes a new RabbitMQ instance.
+// It returns immediately and connects in the background, so the broker does not need to be up at startup.
+func NewRabbitMQ(host string, port int, queueName string, retryPolicy RetryPolicy) *RabbitMQ {
+	if retryPolicy.MaxRetries <= 0 {
+		retryPolicy.MaxRetries = defaultMaxRetries
+	}
+	if retryPolicy.BaseDelay <= 0 {
+		retryPolicy.BaseDelay = defaultRetryBaseDelay
+	}
+
+	r := &RabbitMQ{
+		QueueName:   queueName,
+		RetryPolicy: retryPolicy,
+		url:         fmt.Sprintf("amqp://%s:%d/", host, port),
+		state:       StateConnecting,
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go r.maintainConnection()
+	return r
+}
+
+// State reports whether the broker connection is currently usable
+func (r *RabbitMQ) State() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// WaitConnected blocks until a session is established or timeout elapses
+func (r *RabbitMQ) WaitConnected(timeout time.Duration) error {
+	r.mu.RLock()
+	ready := r.ready
+	r.mu.RUnlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-r.done:
+		return ErrClosed
+	case <-time.After(timeout):
+		return ErrNotConnected
+	}
+}
+
+// maintainConnection connects, waits for the session to die, and reconnects with exponential backoff until Close
+func (r *RabbitMQ) maintainConnection() {
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		conn, ch, err := r.connect()
+		if err != nil {
+			log.Printf("Failed to connect to RabbitMQ, retrying in %s: %v", delay, err)
+			select {
+			case <-r.done:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+
+		delay = minReconnectDelay
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+		r.setSession(conn, ch)
+		log.Printf("Connected to RabbitMQ")
+
+		select {
+		case <-r.done:
+			conn.Close()
+			return
+		case err := <-connClosed:
+			log.Printf("RabbitMQ connection closed: %v", err)
+		case err := <-chClosed:
+			// A closed channel leaves the connection useless to us, so start over
+			log.Printf("RabbitMQ channel closed: %v", err)
+			conn.Close()
+		}
+		r.clearSession()
+	}
 }
 
-// NewRabbitMQ initializes a new RabbitMQ instance
-func NewRabbitMQ(host string, port int, queueName string) *RabbitMQ {
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%d/", host, port))
+// connect dials the broker, opens a channel and (re)declares the queue topology
+func (r *RabbitMQ) connect() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(r.url)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		return nil, nil, err
 	}
 
 	ch, err := conn.Channel()
 	if err != nil {
-		log.Fatalf("Failed to open a channel: %v", err)
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open a channel: %w", err)
 	}
 
-	_, err = ch.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,       // arguments
+	if err := r.declareTopology(ch); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to declare a queue: %w", err)
+	}
+	return conn, ch, nil
+}
+
+// setSession publishes a freshly established session to publishers and consumers
+func (r *RabbitMQ) setSession(conn *amqp.Connection, ch *amqp.Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateClosed {
+		conn.Close()
+		return
+	}
+	r.connection = conn
+	r.channel = ch
+	r.state = StateConnected
+	close(r.ready)
+}
+
+// clearSession marks the session as lost until the next reconnect
+func (r *RabbitMQ) clearSession() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateClosed {
+		return
+	}
+	r.connection = nil
+	r.channel = nil
+	r.state = StateConnecting
+	r.ready = make(chan struct{})
+}
+
+// currentChannel returns the session channel, or ErrNotConnected while reconnecting
+func (r *RabbitMQ) currentChannel() (*amqp.Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.channel == nil {
+		return nil, ErrNotConnected
+	}
+	return r.channel, nil
+}
+
+// currentConnection returns the session connection, or ErrNotConnected while reconnecting
+func (r *RabbitMQ) currentConnection() (*amqp.Connection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.connection == nil {
+		return nil, ErrNotConnected
+	}
+	return r.connection, nil
+}
+
+// waitForChannel blocks until a session channel is available, ctx is cancelled or the manager is closed
+func (r *RabbitMQ) waitForChannel(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		r.mu.RLock()
+		ch, ready := r.channel, r.ready
+		r.mu.RUnlock()
+		if ch != nil {
+			return ch, nil
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.done:
+			return nil, ErrClosed
+		}
+	}
+}
+
+// declareTopology declares the work queue, one delay queue per retry attempt and the dead-letter queue
+func (r *RabbitMQ) declareTopology(ch *amqp.Channel) error {
+	_, err := ch.QueueDeclare(
+		r.QueueName, // name
+		true,        // durable
+		false,       // delete when unused
+		false,       // exclusive
+		false,       // no-wait
+		nil,         // arguments
 	)
 	if err != nil {
-		log.Fatalf("Failed to declare a queue: %v", err)
+		return err
 	}
 
-	return &RabbitMQ{
-		Connection: conn,
-		Channel:    ch,
-		QueueName:  queueName,
+	// Delay queues have no consumers; expired messages are dead-lettered back onto the work queue.
+	// Queue arguments cannot change once declared, so each queue is named after its delay: a new
+	// retry policy declares new queues and the old ones keep draining into the work queue.
+	for attempt := 1; attempt <= r.RetryPolicy.MaxRetries; attempt++ {
+		_, err := ch.QueueDeclare(
+			r.retryQueueName(attempt),
+			true,
+			false,
+			false,
+			false,
+			amqp.Table{
+				"x-message-ttl":             r.retryDelay(attempt).Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": r.QueueName,
+			},
+		)
+		if err != nil {
+			return err
+		}
 	}
-}
 
-// PublishMessage publishes a message to the queue
-func (r *RabbitMQ) PublishMessage(message string) error {
-	return r.Channel.Publish(
-		"",            // exchange
-		r.QueueName,   // routing key
-		false,         // mandatory
-		false,         // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(message),
-		},
+	_, err = ch.QueueDeclare(
+		r.DeadLetterQueueName(),
+		true,
+		false,
+		false,
+		false,
+		nil,
 	)
+	return err
+}
+
+// DeadLetterQueueName returns the queue holding messages that exhausted their retries
+func (r *RabbitMQ) DeadLetterQueueName() string {
+	return r.QueueName + ".dead"
+}
+
+// retryQueueName returns the delay queue used before the given retry attempt, e.g. images.retry.10000ms
+func (r *RabbitMQ) retryQueueName(attempt int) string {
+	return fmt.Sprintf("%s.retry.%dms", r.QueueName, r.retryDelay(attempt).Milliseconds())
 }
 
-// ConsumeMessages starts consuming messages from the queue
-func (r *RabbitMQ) ConsumeMessages() <-chan string {
-	msgs, err := r.Channel.Consume(
+// retryDelay returns the exponential backoff before the given retry attempt
+func (r *RabbitMQ) retryDelay(attempt int) time.Duration {
+	return r.RetryPolicy.BaseDelay * time.Duration(1<<(attempt-1))
+}
+
+// ConsumeMessages starts consuming messages from the queue with at most prefetch unacknowledged deliveries.
+// Deliveries must be settled with Ack, Retry or DeadLetter; unsettled messages are redelivered if the consumer dies.
+// The returned channel survives reconnects and is closed once ctx is cancelled or Close is called.
+func (r *RabbitMQ) ConsumeMessages(ctx context.Context, prefetch int) <-chan Delivery {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			ch, err := r.waitForChannel(ctx)
+			if err != nil {
+				return
+			}
+			if !r.consumeSession(ctx, ch, prefetch, out) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// consumeSession forwards deliveries from one channel until it closes, returning false once ctx is cancelled
+func (r *RabbitMQ) consumeSession(ctx context.Context, ch *amqp.Channel, prefetch int, out chan<- Delivery) bool {
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		log.Printf("Failed to set consumer prefetch: %v", err)
+		return r.sleep(ctx, minReconnectDelay)
+	}
+
+	consumerTag := fmt.Sprintf("%s-consumer-%d", r.QueueName, time.Now().UnixNano())
+	msgs, err := ch.Consume(
 		r.QueueName, // queue
-		"",          // consumer
-		true,        // auto-ack
+		consumerTag, // consumer
+		false,       // auto-ack
 		false,       // exclusive
 		false,       // no-local
 		false,       // no-wait
 		nil,         // args
 	)
 	if err != nil {
-		log.Fatalf("Failed to register a consumer: %v", err)
+		log.Printf("Failed to register a consumer: %v", err)
+		return r.sleep(ctx, minReconnectDelay)
 	}
 
-	// Convert messages to a string channel
-	out := make(chan string)
-	go func() {
-		defer close(out)
-		for d := range msgs {
-			out <- string(d.Body)
+	// Wrap deliveries so consumers can settle them; msgs closes when the session dies
+	for {
+		select {
+		case <-ctx.Done():
+			ch.Cancel(consumerTag, false)
+			return false
+		case <-r.done:
+			return false
+		case d, ok := <-msgs:
+			if !ok {
+				return true
+			}
+			select {
+			case out <- Delivery{delivery: d, channel: ch, queue: r}:
+			case <-ctx.Done():
+				// Nobody will process it; hand it back to the broker
+				d.Nack(false, true)
+				ch.Cancel(consumerTag, false)
+				return false
+			case <-r.done:
+				// Close tore the connection down, so the broker requeues the unacknowledged delivery itself
+				return false
+			}
 		}
-	}()
-	return out
+	}
 }
 
-// Close closes the RabbitMQ connection
+// sleep waits for delay, returning false if ctx is cancelled or the manager is closed first
+func (r *RabbitMQ) sleep(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.done:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// Close stops reconnecting and closes the RabbitMQ connection
 func (r *RabbitMQ) Close() {
-	r.Channel.Close()
-	r.Connection.Close()
+	r.closeOnce.Do(func() {
+		close(r.done)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.connection != nil {
+			r.connection.Close()
+		}
+		r.connection = nil
+		r.channel = nil
+		r.state = StateClosed
+	})
 }
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"product-management-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// imageHashBands is how many 16-bit bands a hash is split into for indexed lookups.
+// Hashes within imageHashBands-1 bits of each other always share a band.
+const imageHashBands = 4
+
+// MaxIndexedHashDistance is the largest distance the band indexes can serve without missing matches
+const MaxIndexedHashDistance = imageHashBands - 1
+
+// similarScanLimit is how many of the most recently hashed images FindSimilar examines when
+// maxDistance is too wide for the band indexes, which keeps such lookups bounded on large catalogs
+const similarScanLimit = 100000
+
+// hashBands splits a hash into the band values stored in the band0..band3 columns
+func hashBands(hash int64) [imageHashBands]int {
+	var bands [imageHashBands]int
+	for i := range bands {
+		bands[i] = int(uint64(hash) >> (48 - 16*i) & 0xffff)
+	}
+	return bands
+}
+
+// hammingDistance is the SQL expression for the number of differing bits between image_hashes.hash and a parameter
+const hammingDistance = "length(replace(((h.hash # ?)::bit(64))::text, '0', ''))"
+
+// ImageHashRepository handles database interactions for perceptual image hashes
+type ImageHashRepository struct {
+	DB *gorm.DB
+}
+
+// NewImageHashRepository creates a new ImageHashRepository
+func NewImageHashRepository(db *gorm.DB) *ImageHashRepository {
+	return &ImageHashRepository{DB: db}
+}
+
+// SaveHash inserts or replaces the hashes stored for a product image position
+func (r *ImageHashRepository) SaveHash(hash *models.ImageHash) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "image_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source_url", "hash", "content_hash", "updated_at"}),
+	}).Create(hash).Error
+}
+
+// ListHashes retrieves the stored hashes of a product's images
+func (r *ImageHashRepository) ListHashes(productID uint) ([]models.ImageHash, error) {
+	var hashes []models.ImageHash
+	err := r.DB.Where("product_id = ?", productID).Order("image_index").Find(&hashes).Error
+	return hashes, err
+}
+
+// FindSimilar retrieves up to limit current images of other products within maxDistance bits of hash, closest first.
+// Up to MaxIndexedHashDistance the candidates come from the band indexes and the search is complete; wider
+// searches compare against the similarScanLimit most recently hashed images instead of the whole table,
+// and complete is false when older images were left out.
+// Rows whose image was replaced since hashing are skipped by comparing against the product's image list.
+func (r *ImageHashRepository) FindSimilar(hash int64, maxDistance int, excludeProductID uint, limit int) (matches []models.ImageMatch, complete bool, err error) {
+	candidates := `SELECT * FROM image_hashes ORDER BY updated_at DESC LIMIT ?`
+	candidateArgs := []interface{}{similarScanLimit}
+	complete = true
+	if maxDistance <= MaxIndexedHashDistance {
+		bands := hashBands(hash)
+		candidates = `SELECT * FROM image_hashes WHERE band0 = ? OR band1 = ? OR band2 = ? OR band3 = ?`
+		candidateArgs = []interface{}{bands[0], bands[1], bands[2], bands[3]}
+	} else {
+		// Counting stops one past the scan window, so this stays cheap however large the table is
+		var hashed int64
+		err = r.DB.Raw(`SELECT count(*) FROM (SELECT 1 FROM image_hashes LIMIT ?) t`, similarScanLimit+1).Scan(&hashed).Error
+		if err != nil {
+			return nil, false, err
+		}
+		complete = hashed <= similarScanLimit
+	}
+
+	args := append([]interface{}{hash}, candidateArgs...)
+	args = append(args, excludeProductID, hash, maxDistance, limit)
+	err = r.DB.Raw(`
+		SELECT h.product_id, h.image_index, h.source_url, `+hammingDistance+` AS distance
+		FROM (`+candidates+`) h
+		JOIN products p ON p.id = h.product_id AND p.product_images[h.image_index + 1] = h.source_url
+		WHERE h.product_id <> ? AND `+hammingDistance+` <= ?
+		ORDER BY distance, h.product_id, h.image_index
+		LIMIT ?`,
+		args...,
+	).Scan(&matches).Error
+	if err != nil {
+		return nil, false, err
+	}
+	return matches, complete, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"product-management-system/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository handles database interactions for outbox messages
+type OutboxRepository struct {
+	DB *gorm.DB
+}
+
+// NewOutboxRepository creates a new OutboxRepository
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{DB: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *OutboxRepository) Transaction(fn func(tx *OutboxRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(NewOutboxRepository(tx))
+	})
+}
+
+// Enqueue inserts outbox messages
+func (r *OutboxRepository) Enqueue(messages []models.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return r.DB.Create(&messages).Error
+}
+
+// ClaimPending locks up to limit unsent messages in insertion order.
+// SKIP LOCKED lets several relays share the table without publishing the same row twice.
+// It must be called inside Transaction so the locks last until the rows are marked.
+func (r *OutboxRepository) ClaimPending(limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := r.DB.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("sent_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// MarkSent records that messages were confirmed by the broker
+func (r *OutboxRepository) MarkSent(ids []uint, sentAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.DB.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("sent_at", sentAt).Error
+}
+
+// RecordFailure increments a message's attempt count and stores the error
+func (r *OutboxRepository) RecordFailure(id uint, reason string) error {
+	return r.DB.Model(&models.OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": reason,
+	}).Error
+}
+
+// DeleteSentBefore removes messages confirmed before cutoff
+func (r *OutboxRepository) DeleteSentBefore(cutoff time.Time) (int64, error) {
+	result := r.DB.Where("sent_at < ?", cutoff).Delete(&models.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -1,20 +1,40 @@
 package repository
 
 import (
+	"fmt"
+	"html"
 	"product-management-system/internal/models"
 	"product-management-system/internal/shared"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// ProductRepository handles database interactions for products
+// ProductRepository handles database interactions for products.
+// SearchLanguage is the text search configuration products.search_vector was generated with.
 type ProductRepository struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	SearchLanguage string
 }
 
 // NewProductRepository creates a new ProductRepository
-func NewProductRepository(db *gorm.DB) *ProductRepository {
-	return &ProductRepository{DB: db}
+func NewProductRepository(db *gorm.DB, searchLanguage string) *ProductRepository {
+	return &ProductRepository{DB: db, SearchLanguage: searchLanguage}
+}
+
+// Transaction runs fn with product and outbox repositories bound to a single database transaction
+func (r *ProductRepository) Transaction(fn func(products *ProductRepository, outbox *OutboxRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(NewProductRepository(tx, r.SearchLanguage), NewOutboxRepository(tx))
+	})
+}
+
+// ImageHashes returns an image hash repository sharing this repository's connection
+func (r *ProductRepository) ImageHashes() *ImageHashRepository {
+	return NewImageHashRepository(r.DB)
 }
 
 // CreateProduct inserts a new product into the database
@@ -23,19 +43,317 @@ func (r *ProductRepository) CreateProduct(product *models.Product) error {
 	return r.DB.Create(product).Error
 }
 
-
 // GetProductByID retrieves a product by its ID
 func (r *ProductRepository) GetProductByID(id uint) (*models.Product, error) {
 	var product models.Product
 	err := r.DB.First(&product, id).Error
+	product.ImagePlaceholders = product.AlignedPlaceholders()
 	return &product, err
 }
 
-// ListProducts retrieves all products with optional filters
-// ListProducts retrieves all products with optional filters
-func (r *ProductRepository) ListProducts(filter shared.ProductFilter) ([]models.Product, error) {
-	var products []models.Product
-	query := r.DB.Model(&models.Product{}).Where("user_id = ?", filter.UserID)
+// GetProductForUpdate retrieves a product and locks its row until the surrounding transaction ends
+func (r *ProductRepository) GetProductForUpdate(id uint) (*models.Product, error) {
+	var product models.Product
+	err := r.DB.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error
+	product.ImagePlaceholders = product.AlignedPlaceholders()
+	return &product, err
+}
+
+// UpdateProduct persists all fields of an existing product
+func (r *ProductRepository) UpdateProduct(product *models.Product) error {
+	return r.DB.Save(product).Error
+}
+
+// SetProcessedImage merges the renditions of the image at index into the product, stores its
+// placeholder and perceptual hash, and marks it succeeded. The largest rendition also becomes the
+// image's entry in CompressedImages. It reports false without error when the product no longer
+// has sourceURL at that index, which happens when the image list was edited after the job was queued.
+// It also returns the URLs of renditions the merge replaced, which the product no longer references.
+func (r *ProductRepository) SetProcessedImage(id uint, index int, sourceURL string, result models.ProcessedImage, completedAt time.Time) (bool, []string, error) {
+	var released []string
+	updated, err := r.updateImage(id, index, sourceURL, func(tx *gorm.DB, product *models.Product, status *models.ImageStatus) (map[string]interface{}, error) {
+		before := product.ReferencedURLs()
+
+		err := NewImageHashRepository(tx).SaveHash(&models.ImageHash{
+			ProductID:   id,
+			ImageIndex:  index,
+			SourceURL:   sourceURL,
+			Hash:        result.Hash,
+			ContentHash: result.ContentHash,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep per-image columns positionally aligned with the source images
+		allVariants := make(models.ImageVariantsList, len(product.ProductImages))
+		copy(allVariants, product.ImageVariants)
+		merged := models.ImageVariants{}
+		for name, variant := range allVariants[index] {
+			merged[name] = variant
+		}
+		for name, variant := range result.Variants {
+			merged[name] = variant
+		}
+		allVariants[index] = merged
+
+		images := make(models.StringArray, len(product.ProductImages))
+		copy(images, product.CompressedImages)
+		if largest, ok := merged.Largest(); ok {
+			images[index] = largest.URL
+		}
+
+		placeholders := product.AlignedPlaceholders()
+		placeholders[index] = result.Placeholder
+
+		after := *product
+		after.ImageVariants = allVariants
+		after.CompressedImages = images
+		released = models.ReleasedURLs(before, &after)
+
+		status.State = models.ImageStateSucceeded
+		status.Error = ""
+		status.CompletedAt = &completedAt
+		status.UpdatedAt = &completedAt
+		return map[string]interface{}{
+			"ImageVariants":     allVariants,
+			"CompressedImages":  images,
+			"ImagePlaceholders": placeholders,
+		}, nil
+	})
+	if !updated || err != nil {
+		released = nil
+	}
+	return updated, released, err
+}
+
+// UpdateImageStatus applies update to the status of the image at index.
+// Like SetProcessedImage it reports false when the image at index is no longer sourceURL.
+func (r *ProductRepository) UpdateImageStatus(id uint, index int, sourceURL string, update func(status *models.ImageStatus)) (bool, error) {
+	return r.updateImage(id, index, sourceURL, func(_ *gorm.DB, _ *models.Product, status *models.ImageStatus) (map[string]interface{}, error) {
+		update(status)
+		return map[string]interface{}{}, nil
+	})
+}
+
+// updateImage locks the product row, checks the image at index is still sourceURL,
+// and saves the column changes returned by fn together with the status fn edited
+func (r *ProductRepository) updateImage(id uint, index int, sourceURL string, fn func(tx *gorm.DB, product *models.Product, status *models.ImageStatus) (map[string]interface{}, error)) (bool, error) {
+	updated := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error; err != nil {
+			return err
+		}
+		if index < 0 || index >= len(product.ProductImages) || product.ProductImages[index] != sourceURL {
+			return nil
+		}
+
+		statuses := product.AlignedImageStatus()
+		changes, err := fn(tx, &product, &statuses[index])
+		if err != nil {
+			return err
+		}
+		changes["ImageStatus"] = statuses
+
+		updated = true
+		return tx.Model(&product).Updates(changes).Error
+	})
+	return updated, err
+}
+
+// DeleteProduct removes a product by its ID
+func (r *ProductRepository) DeleteProduct(id uint) error {
+	return r.DB.Delete(&models.Product{}, id).Error
+}
+
+// productSortColumns maps each sort key to the column it orders by
+var productSortColumns = map[shared.ProductSort]string{
+	shared.SortByCreatedAt: "created_at",
+	shared.SortByPrice:     "product_price",
+	shared.SortByName:      "product_name",
+	shared.SortByRelevance: searchRankExpression,
+}
+
+// searchRankExpression scores a search match; normalization 1 keeps long descriptions from outranking short names
+const searchRankExpression = "ts_rank(search_vector, search_query, 1)"
+
+// ts_headline wraps matches in these private-use characters, which become <mark> tags once the text is escaped
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+var (
+	nameHeadlineOptions    = fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+	snippetHeadlineOptions = fmt.Sprintf(`StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "`, highlightStart, highlightStop)
+)
+
+// productSearchRow is a product with the rank and highlights computed by a search
+type productSearchRow struct {
+	models.Product
+	SearchRank    float64
+	NameHighlight string
+	Snippet       string
+}
+
+// ListProducts retrieves one page of products matching the filter.
+// Pages are ordered by the sort column and then ID, which the keyset cursor compares as a row value.
+func (r *ProductRepository) ListProducts(filter shared.ProductFilter) (*shared.ProductPage, error) {
+	page := &shared.ProductPage{Limit: filter.Limit}
+	if filter.IncludeTotal {
+		var total int64
+		if err := r.filteredProducts(filter).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	column, ok := productSortColumns[filter.Sort]
+	if !ok || (filter.Sort == shared.SortByRelevance && filter.Query == "") {
+		column = productSortColumns[shared.SortByCreatedAt]
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.filteredProducts(filter)
+	if filter.After != nil {
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), filter.After.Value(), filter.After.ID)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	// One extra row tells whether another page follows
+	query = query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).Limit(filter.Limit + 1)
+
+	items, err := r.findListItems(query, filter.Query != "")
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > filter.Limit {
+		items = items[:filter.Limit]
+		cursor := shared.NewProductCursor(items[len(items)-1], filter.Sort, filter.Descending)
+		page.NextCursor = cursor.Encode()
+	}
+	page.Products = items
+	return page, nil
+}
+
+// findListItems runs a listing query, selecting the rank and highlights as well when it is a search
+func (r *ProductRepository) findListItems(query *gorm.DB, search bool) ([]shared.ProductListItem, error) {
+	if !search {
+		var products []models.Product
+		if err := query.Find(&products).Error; err != nil {
+			return nil, err
+		}
+		items := make([]shared.ProductListItem, len(products))
+		for i, product := range products {
+			product.ImagePlaceholders = product.AlignedPlaceholders()
+			items[i] = shared.ProductListItem{Product: product}
+		}
+		return items, nil
+	}
+
+	var rows []productSearchRow
+	err := query.Select(
+		"products.*, "+searchRankExpression+" AS search_rank, "+
+			"ts_headline(?::regconfig, product_name, search_query, ?) AS name_highlight, "+
+			"ts_headline(?::regconfig, coalesce(product_description, ''), search_query, ?) AS snippet",
+		r.SearchLanguage, nameHeadlineOptions, r.SearchLanguage, snippetHeadlineOptions,
+	).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	items := make([]shared.ProductListItem, len(rows))
+	for i, row := range rows {
+		row.Product.ImagePlaceholders = row.Product.AlignedPlaceholders()
+		items[i] = shared.ProductListItem{
+			Product: row.Product,
+			Search: &shared.SearchMatch{
+				Rank:          row.SearchRank,
+				NameHighlight: highlight(row.NameHighlight),
+				Snippet:       highlight(row.Snippet),
+			},
+		}
+	}
+	return items, nil
+}
+
+// PriceFacet counts the products matching the filter in each price bucket.
+// bounds are the ascending bucket edges, so there is one count below the first edge and one per edge.
+func (r *ProductRepository) PriceFacet(filter shared.ProductFilter, bounds []float64) ([]int64, error) {
+	// The edges are passed as an array literal; gorm would expand a slice argument into a list
+	edges := make([]string, len(bounds))
+	for i, bound := range bounds {
+		edges[i] = strconv.FormatFloat(bound, 'f', -1, 64)
+	}
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := r.filteredProducts(filter).
+		Select("width_bucket(product_price::float8, ?::float8[]) AS bucket, count(*) AS count", "{"+strings.Join(edges, ",")+"}").
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(bounds)+1)
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+	return counts, nil
+}
+
+// CategoryFacet counts the products matching the filter per category, most common first
+func (r *ProductRepository) CategoryFacet(filter shared.ProductFilter, limit int) ([]shared.FacetCount, error) {
+	counts := []shared.FacetCount{}
+	err := r.filteredProducts(filter).
+		Select("category AS value, count(*) AS count").
+		Where("category <> ''").
+		Group("category").
+		Order("count DESC, value").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// TagFacet counts the products matching the filter per tag, most common first
+func (r *ProductRepository) TagFacet(filter shared.ProductFilter, limit int) ([]shared.FacetCount, error) {
+	counts := []shared.FacetCount{}
+	err := r.filteredProducts(filter).
+		Joins("CROSS JOIN unnest(tags) AS tag").
+		Select("tag AS value, count(*) AS count").
+		Group("tag").
+		Order("count DESC, value").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// SellerFacet counts the products matching the filter per seller, most products first
+func (r *ProductRepository) SellerFacet(filter shared.ProductFilter, limit int) ([]shared.SellerCount, error) {
+	counts := []shared.SellerCount{}
+	err := r.filteredProducts(filter).
+		Select("user_id, count(*) AS count").
+		Group("user_id").
+		Order("count DESC, user_id").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// filteredProducts builds a query for the products matching the filter, without paging.
+// A zero UserID spans every seller, which only the seller facet relies on.
+func (r *ProductRepository) filteredProducts(filter shared.ProductFilter) *gorm.DB {
+	query := r.DB.Model(&models.Product{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
 
 	// Apply additional filters
 	if filter.MinPrice > 0 {
@@ -47,9 +365,32 @@ func (r *ProductRepository) ListProducts(filter shared.ProductFilter) ([]models.
 	if filter.ProductName != "" {
 		query = query.Where("product_name ILIKE ?", "%"+filter.ProductName+"%")
 	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Tag != "" {
+		query = query.Where("tags @> ARRAY[?]::text[]", filter.Tag)
+	}
+	if filter.Query != "" {
+		query = query.Joins("CROSS JOIN to_tsquery(?::regconfig, ?) AS search_query", r.SearchLanguage, prefixQuery(filter.Query)).
+			Where("search_vector @@ search_query")
+	}
+	return query
+}
 
-	// Execute the query
-	err := query.Find(&products).Error
-	return products, err
+// prefixQuery turns free text into a tsquery requiring every word, each matched as a prefix so results follow typing.
+// Only letters and digits survive SearchTerms, so user input can never form tsquery operators.
+func prefixQuery(text string) string {
+	terms := shared.SearchTerms(text)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
 }
 
+// highlight escapes ts_headline output for HTML and turns its match markers into <mark> tags
+func highlight(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
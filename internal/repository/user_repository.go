@@ -27,3 +27,10 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	err := r.DB.Where("email = ?", email).First(&user).Error
 	return &user, err
 }
+
+// GetUserByID retrieves a user by their ID
+func (r *UserRepository) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+	err := r.DB.First(&user, id).Error
+	return &user, err
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"product-management-system/config"
+
+	// Register the GIF decoder for image.Decode; JPEG and PNG are registered by the imports above
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+)
+
+// rendition is an encoded variant of a source image
+type rendition struct {
+	data   []byte
+	ext    string
+	format string
+	width  int
+	height int
+}
+
+// renderVariant downsizes img to fit within the variant bounds and re-encodes it.
+// Opaque images become JPEG; images with transparency stay PNG so the alpha channel survives.
+// The encoders write no metadata, so outputs carry only the artist and copyright in embed, if any.
+func renderVariant(img image.Image, spec config.ImageVariantSpec, embed imageMetadata) (rendition, error) {
+	resized := resizeToFit(img, spec.MaxWidth, spec.MaxHeight)
+	result := rendition{width: resized.Bounds().Dx(), height: resized.Bounds().Dy()}
+
+	var buf bytes.Buffer
+	if isOpaque(resized) {
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: spec.Quality}); err != nil {
+			return rendition{}, err
+		}
+		data, err := stripJPEGMetadata(buf.Bytes(), embed)
+		if err != nil {
+			return rendition{}, err
+		}
+		result.data, result.ext, result.format = data, ".jpg", "jpeg"
+		return result, nil
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, resized); err != nil {
+		return rendition{}, err
+	}
+	data, err := insertPNGText(buf.Bytes(), map[string]string{"Author": embed.Artist, "Copyright": embed.Copyright})
+	if err != nil {
+		return rendition{}, err
+	}
+	result.data, result.ext, result.format = data, ".png", "png"
+	return result, nil
+}
+
+// resizeToFit scales img down, preserving aspect ratio, so it fits within maxWidth x maxHeight.
+// Images already within bounds are returned unchanged; a non-positive bound leaves that axis unconstrained.
+func resizeToFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return img
+	}
+
+	scale := 1.0
+	if maxWidth > 0 {
+		scale = math.Min(scale, float64(maxWidth)/float64(width))
+	}
+	if maxHeight > 0 {
+		scale = math.Min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale >= 1 {
+		return img
+	}
+
+	targetWidth := max(1, int(math.Round(float64(width)*scale)))
+	targetHeight := max(1, int(math.Round(float64(height)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// isOpaque reports whether an image has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
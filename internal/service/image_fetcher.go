@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"product-management-system/config"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrImageRejected wraps source images that can never be processed, such as blocked URLs or non-image payloads.
+// Retrying them is pointless, so the worker dead-letters the job straight away.
+var ErrImageRejected = errors.New("image rejected")
+
+const (
+	defaultMaxRedirects    = 5
+	defaultMaxSourcePixels = 50_000_000
+)
+
+// defaultAllowedSchemes are the URL schemes fetched when none are configured
+var defaultAllowedSchemes = []string{"https", "http"}
+
+// blockedPrefixes are special-purpose ranges not covered by the net/netip helpers
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which can reach IPv4 private ranges
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, which embeds arbitrary IPv4 addresses
+	netip.MustParsePrefix("2001::/32"),      // Teredo
+}
+
+// imageFetcher downloads remote source images without letting user-supplied URLs reach internal services.
+// Addresses are checked when each connection is dialed, after DNS resolution, so redirects and
+// DNS rebinding cannot smuggle a request to a private host.
+type imageFetcher struct {
+	client *http.Client
+	config config.ImageProcessingConfig
+}
+
+// newImageFetcher creates an imageFetcher enforcing config's schemes, redirect, size and time limits
+func newImageFetcher(config config.ImageProcessingConfig) *imageFetcher {
+	f := &imageFetcher{config: config}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: f.checkDial,
+	}
+	f.client = &http.Client{
+		Timeout: config.DownloadTimeout,
+		Transport: &http.Transport{
+			// Never route through an environment proxy, which would dial on our behalf unchecked
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: config.DownloadTimeout,
+			MaxIdleConns:          config.Workers,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.MaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrImageRejected, config.MaxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Fetch downloads an image, refusing non-200 responses and bodies over the size limit
+func (f *imageFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid image URL: %v", ErrImageRejected, err)
+	}
+	if err := f.checkURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid image URL: %v", ErrImageRejected, err)
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Dial and redirect policy violations stay recognisable through the *url.Error wrapping
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("failed to download image: unexpected status %d", resp.StatusCode)
+		if !retryableStatus(resp.StatusCode) {
+			err = fmt.Errorf("%w: %v", ErrImageRejected, err)
+		}
+		return nil, err
+	}
+	if resp.ContentLength > f.config.MaxDownloadBytes {
+		return nil, fmt.Errorf("%w: image exceeds %d bytes", ErrImageRejected, f.config.MaxDownloadBytes)
+	}
+
+	return readImageData(resp.Body, f.config.MaxDownloadBytes)
+}
+
+// retryableStatus reports whether a failed download may succeed later.
+// Server errors, timeouts and throttling can clear up; other statuses will not.
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// checkURL enforces the scheme allowlist and rejects URLs carrying credentials or literal blocked addresses
+func (f *imageFetcher) checkURL(target *url.URL) error {
+	if !slices.Contains(f.config.AllowedSchemes, strings.ToLower(target.Scheme)) {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrImageRejected, target.Scheme)
+	}
+	if target.Hostname() == "" {
+		return fmt.Errorf("%w: URL has no host", ErrImageRejected)
+	}
+	if target.User != nil {
+		return fmt.Errorf("%w: URL must not contain credentials", ErrImageRejected)
+	}
+	if addr, err := netip.ParseAddr(target.Hostname()); err == nil && !f.allowedAddr(addr) {
+		return fmt.Errorf("%w: address %s is not allowed", ErrImageRejected, addr)
+	}
+	return nil
+}
+
+// checkDial runs after DNS resolution for every connection attempt, including those for redirects
+func (f *imageFetcher) checkDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: cannot parse dial address %q", ErrImageRejected, address)
+	}
+	if !f.allowedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: address %s is not allowed", ErrImageRejected, addrPort.Addr())
+	}
+	return nil
+}
+
+// allowedAddr reports whether the fetcher may connect to addr
+func (f *imageFetcher) allowedAddr(addr netip.Addr) bool {
+	return f.config.AllowPrivateNetworks || isPublicAddr(addr)
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address.
+// Zoned addresses only make sense on a local link, so they are never public.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.Zone() != "" || !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// readImageData reads at most maxBytes from r, rejecting larger payloads
+func readImageData(r io.Reader, maxBytes int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: image exceeds %d bytes", ErrImageRejected, maxBytes)
+	}
+	return data, nil
+}
+
+// verifyImage checks that data is a supported image format by its magic bytes, and that its
+// header declares no more than maxPixels, before any pixels are decoded
+func verifyImage(data []byte, maxPixels int64) error {
+	contentType := http.DetectContentType(data)
+	if _, ok := uploadExtensions[contentType]; !ok {
+		return fmt.Errorf("%w: unsupported content type %s", ErrImageRejected, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImageRejected, err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrImageRejected, config.Width, config.Height, maxPixels)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// differenceHash computes a 64-bit dHash: the image is shrunk to 9x8 grayscale and each bit
+// records whether a pixel is brighter than its right neighbour. Re-encoded or resized copies of
+// a picture land within a few bits of each other.
+func differenceHash(img image.Image) int64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	// Stored as a signed bigint; only the bit pattern matters
+	return int64(hash)
+}
+
+// sourceContentHash identifies the exact bytes of a source image as hex SHA-256
+func sourceContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// formatHash renders a hash as 16 hex digits
+func formatHash(hash int64) string {
+	return fmt.Sprintf("%016x", uint64(hash))
+}
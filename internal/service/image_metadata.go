@@ -0,0 +1,444 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+)
+
+// EXIF tags read from and written to IFD0
+const (
+	exifTagOrientation = 0x0112
+	exifTagArtist      = 0x013B
+	exifTagCopyright   = 0x8298
+)
+
+// TIFF field types used by the tags above
+const (
+	tiffTypeASCII = 2
+	tiffTypeShort = 3
+)
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	iccHeader    = []byte("ICC_PROFILE\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// reencodeQuality is the JPEG quality of originals that had to be re-encoded to drop their metadata
+const reencodeQuality = 92
+
+// errMalformedMetadata is returned when JPEG segments or PNG chunks cannot be walked
+var errMalformedMetadata = errors.New("malformed image metadata")
+
+// imageMetadata is the subset of embedded metadata the pipeline acts on; everything else is discarded
+type imageMetadata struct {
+	Orientation int
+	Artist      string
+	Copyright   string
+}
+
+// readImageMetadata extracts the EXIF orientation and copyright fields of a JPEG or PNG.
+// Missing or unreadable metadata yields the zero value, which means "upright, no copyright".
+func readImageMetadata(data []byte) imageMetadata {
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		_ = walkJPEGSegments(data, func(marker byte, segment []byte) bool {
+			if marker == 0xE1 && bytes.HasPrefix(segment[4:], exifHeader) {
+				tiff = segment[4+len(exifHeader):]
+				return false
+			}
+			return true
+		})
+	case bytes.HasPrefix(data, pngSignature):
+		_ = walkPNGChunks(data, func(chunkType string, chunk []byte) bool {
+			if chunkType == "eXIf" {
+				tiff = chunk[8 : len(chunk)-4]
+				return false
+			}
+			return true
+		})
+	}
+	if tiff == nil {
+		return imageMetadata{}
+	}
+	return parseTIFFMetadata(tiff)
+}
+
+// parseTIFFMetadata reads the tags of interest from the first IFD of a TIFF structure
+func parseTIFFMetadata(tiff []byte) imageMetadata {
+	var meta imageMetadata
+	if len(tiff) < 8 {
+		return meta
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return meta
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return meta
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[entry:])
+		fieldType := order.Uint16(tiff[entry+2:])
+		valueCount := int(order.Uint32(tiff[entry+4:]))
+		value := tiff[entry+8 : entry+12]
+
+		switch {
+		case tag == exifTagOrientation && fieldType == tiffTypeShort:
+			meta.Orientation = int(order.Uint16(value))
+		case (tag == exifTagArtist || tag == exifTagCopyright) && fieldType == tiffTypeASCII:
+			text := value
+			if valueCount > 4 {
+				offset := int(order.Uint32(value))
+				if offset < 0 || offset+valueCount > len(tiff) {
+					continue
+				}
+				text = tiff[offset : offset+valueCount]
+			}
+			if valueCount < len(text) {
+				text = text[:valueCount]
+			}
+			str := strings.TrimRight(string(text), "\x00 ")
+			if tag == exifTagArtist {
+				meta.Artist = str
+			} else {
+				meta.Copyright = str
+			}
+		}
+	}
+	return meta
+}
+
+// buildTIFFMetadata encodes the non-empty fields of meta as a big-endian TIFF with a single IFD
+func buildTIFFMetadata(meta imageMetadata) []byte {
+	type field struct {
+		tag       uint16
+		fieldType uint16
+		short     uint16
+		text      string
+	}
+	var fields []field
+	if meta.Orientation > 1 {
+		fields = append(fields, field{tag: exifTagOrientation, fieldType: tiffTypeShort, short: uint16(meta.Orientation)})
+	}
+	if meta.Artist != "" {
+		fields = append(fields, field{tag: exifTagArtist, fieldType: tiffTypeASCII, text: meta.Artist})
+	}
+	if meta.Copyright != "" {
+		fields = append(fields, field{tag: exifTagCopyright, fieldType: tiffTypeASCII, text: meta.Copyright})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	order := binary.BigEndian
+	ifdSize := 2 + len(fields)*12 + 4
+	buf := make([]byte, 8+ifdSize)
+	copy(buf, "MM")
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], 8)
+	order.PutUint16(buf[8:], uint16(len(fields)))
+
+	// Fields are already in ascending tag order, as TIFF requires
+	for i, f := range fields {
+		entry := buf[10+i*12 : 22+i*12]
+		order.PutUint16(entry[0:], f.tag)
+		order.PutUint16(entry[2:], f.fieldType)
+		if f.fieldType == tiffTypeShort {
+			order.PutUint32(entry[4:], 1)
+			order.PutUint16(entry[8:], f.short)
+			continue
+		}
+
+		text := append([]byte(f.text), 0)
+		order.PutUint32(entry[4:], uint32(len(text)))
+		if len(text) <= 4 {
+			copy(entry[8:], text)
+			continue
+		}
+		order.PutUint32(entry[8:], uint32(len(buf)))
+		buf = append(buf, text...)
+	}
+	return buf
+}
+
+// stripJPEGMetadata drops every APPn and comment segment except the JFIF header, ICC profile and
+// Adobe color transform, which decoders need, and inserts an EXIF segment holding only meta
+func stripJPEGMetadata(data []byte, meta imageMetadata) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	exifWritten := false
+	writeExif := func() {
+		exifWritten = true
+		tiff := buildTIFFMetadata(meta)
+		if tiff == nil {
+			return
+		}
+		payload := append(append([]byte{}, exifHeader...), tiff...)
+		out.Write([]byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)})
+		out.Write(payload)
+	}
+
+	err := walkJPEGSegments(data, func(marker byte, segment []byte) bool {
+		keep := true
+		switch {
+		case marker == 0xE0: // JFIF
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(segment[4:], iccHeader)
+		case marker == 0xEE: // Adobe
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+			keep = false
+		}
+		if marker != 0xE0 && !exifWritten {
+			writeExif()
+		}
+		if keep {
+			out.Write(segment)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// walkJPEGSegments calls fn with each marker segment before the image data, including its marker and length.
+// The scan data after SOS is not visited; fn returns false to stop early.
+// When every segment is visited, fn is finally called with the SOS marker and the rest of the file.
+func walkJPEGSegments(data []byte, fn func(marker byte, segment []byte) bool) error {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return errMalformedMetadata
+	}
+	for i := 2; i < len(data); {
+		if data[i] != 0xFF {
+			return errMalformedMetadata
+		}
+		// Markers may be preceded by any number of fill bytes
+		for i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+		}
+		if i+1 >= len(data) {
+			return errMalformedMetadata
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			fn(marker, data[i:])
+			return nil
+		}
+		// TEM and RSTn stand alone without a length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			if !fn(marker, data[i:i+2]) {
+				return nil
+			}
+			i += 2
+			continue
+		}
+		if i+4 > len(data) {
+			return errMalformedMetadata
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return errMalformedMetadata
+		}
+		if !fn(marker, data[i:end]) {
+			return nil
+		}
+		i = end
+	}
+	return errMalformedMetadata
+}
+
+// stripPNGMetadata drops text, time and EXIF chunks and inserts an eXIf chunk holding only meta
+func stripPNGMetadata(data []byte, meta imageMetadata) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	err := walkPNGChunks(data, func(chunkType string, chunk []byte) bool {
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+			return true
+		}
+		out.Write(chunk)
+		if chunkType == "IHDR" {
+			if tiff := buildTIFFMetadata(meta); tiff != nil {
+				writePNGChunk(out, "eXIf", tiff)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// insertPNGText adds tEXt chunks after the IHDR chunk of an encoded PNG
+func insertPNGText(data []byte, entries map[string]string) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	err := walkPNGChunks(data, func(chunkType string, chunk []byte) bool {
+		out.Write(chunk)
+		if chunkType == "IHDR" {
+			for _, key := range []string{"Author", "Copyright"} {
+				if value := entries[key]; value != "" {
+					writePNGChunk(out, "tEXt", append([]byte(key+"\x00"), value...))
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// walkPNGChunks calls fn with each chunk, including its length, type and CRC; fn returns false to stop early
+func walkPNGChunks(data []byte, fn func(chunkType string, chunk []byte) bool) error {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return errMalformedMetadata
+	}
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return errMalformedMetadata
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i+12 {
+			return errMalformedMetadata
+		}
+		chunkType := string(data[i+4 : i+8])
+		if !fn(chunkType, data[i:end]) || chunkType == "IEND" {
+			return nil
+		}
+		i = end
+	}
+	return errMalformedMetadata
+}
+
+// writePNGChunk appends a chunk with its length and CRC
+func writePNGChunk(out *bytes.Buffer, chunkType string, payload []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	copy(header[4:], chunkType)
+	out.Write(header[:])
+	out.Write(payload)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	out.Write(sum[:])
+}
+
+// applyOrientation returns img transformed so it displays upright for an EXIF orientation value.
+// Values outside 2-8 leave the image unchanged.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// sanitizeOriginal strips metadata from an uploaded JPEG or PNG before it is stored, keeping only
+// the orientation, which the worker still needs, and the artist and copyright fields when preserveCopyright is set.
+// Files whose segments cannot be walked are re-encoded instead; other formats are returned unchanged.
+func sanitizeOriginal(data []byte, preserveCopyright bool) ([]byte, error) {
+	meta := readImageMetadata(data)
+	if !preserveCopyright {
+		meta = imageMetadata{Orientation: meta.Orientation}
+	}
+	var sanitized []byte
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		sanitized, err = stripJPEGMetadata(data, meta)
+	case bytes.HasPrefix(data, pngSignature):
+		sanitized, err = stripPNGMetadata(data, meta)
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return reencodeOriginal(data, meta)
+	}
+	return sanitized, nil
+}
+
+// reencodeOriginal decodes and re-encodes an image, which drops all of its metadata.
+// The orientation is applied to the pixels first because the tag does not survive.
+func reencodeOriginal(data []byte, meta imageMetadata) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img = applyOrientation(img, meta.Orientation)
+
+	var out bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&out, img)
+	} else {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: reencodeQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// taggedImage encodes a small JPEG or PNG carrying an EXIF orientation, artist and copyright
+func taggedImage(t *testing.T, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var encoded bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&encoded, img)
+	} else {
+		err = jpeg.Encode(&encoded, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+
+	meta := imageMetadata{Orientation: 6, Artist: "Jane Doe", Copyright: "(c) Jane Doe"}
+	var tagged []byte
+	if format == "png" {
+		tagged, err = stripPNGMetadata(encoded.Bytes(), meta)
+	} else {
+		tagged, err = stripJPEGMetadata(encoded.Bytes(), meta)
+	}
+	if err != nil {
+		t.Fatalf("tag %s: %v", format, err)
+	}
+	if got := readImageMetadata(tagged); got != meta {
+		t.Fatalf("tagged %s metadata = %+v, want %+v", format, got, meta)
+	}
+	return tagged
+}
+
+func TestSanitizeOriginalKeepsOnlyOrientationByDefault(t *testing.T) {
+	for _, format := range []string{"jpeg", "png"} {
+		t.Run(format, func(t *testing.T) {
+			sanitized, err := sanitizeOriginal(taggedImage(t, format), false)
+			if err != nil {
+				t.Fatalf("sanitizeOriginal: %v", err)
+			}
+			want := imageMetadata{Orientation: 6}
+			if got := readImageMetadata(sanitized); got != want {
+				t.Errorf("metadata = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSanitizeOriginalPreservesCopyright(t *testing.T) {
+	for _, format := range []string{"jpeg", "png"} {
+		t.Run(format, func(t *testing.T) {
+			sanitized, err := sanitizeOriginal(taggedImage(t, format), true)
+			if err != nil {
+				t.Fatalf("sanitizeOriginal: %v", err)
+			}
+			want := imageMetadata{Orientation: 6, Artist: "Jane Doe", Copyright: "(c) Jane Doe"}
+			if got := readImageMetadata(sanitized); got != want {
+				t.Errorf("metadata = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
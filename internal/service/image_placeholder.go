@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"product-management-system/internal/models"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// placeholderSampleSize is the edge of the thumbnail placeholders are computed from; more pixels add nothing
+const placeholderSampleSize = 32
+
+const base83Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// computePlaceholder derives the blurhash and dominant color shown while an image loads
+func computePlaceholder(img image.Image) models.ImagePlaceholder {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return models.ImagePlaceholder{}
+	}
+
+	sample := image.NewNRGBA(image.Rect(0, 0, placeholderSampleSize, placeholderSampleSize))
+	draw.ApproxBiLinear.Scale(sample, sample.Bounds(), img, bounds, draw.Src, nil)
+
+	// Match the component grid to the aspect ratio so detail is spent along the longer edge
+	xComponents, yComponents := 4, 3
+	if bounds.Dy() > bounds.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	return models.ImagePlaceholder{
+		Blurhash:      blurhash(sample, xComponents, yComponents),
+		DominantColor: dominantColor(sample),
+	}
+}
+
+// blurhash encodes img with the given number of cosine components per axis, following the reference algorithm
+func blurhash(img *image.NRGBA, xComponents, yComponents int) string {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					offset := img.PixOffset(x, y)
+					factor[0] += basis * srgbToLinear(img.Pix[offset])
+					factor[1] += basis * srgbToLinear(img.Pix[offset+1])
+					factor[2] += basis * srgbToLinear(img.Pix[offset+2])
+				}
+			}
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximum := 0.0
+		for _, factor := range ac {
+			for _, component := range factor {
+				actualMaximum = math.Max(actualMaximum, math.Abs(component))
+			}
+		}
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encode83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, factor := range ac {
+		quantised := [3]int{}
+		for c, component := range factor {
+			quantised[c] = int(math.Max(0, math.Min(18, math.Floor(signPow(component/maximumValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quantised[0]*19*19+quantised[1]*19+quantised[2], 2))
+	}
+	return hash.String()
+}
+
+// dominantColor returns the average of the most populated bucket of a coarse color histogram as #rrggbb.
+// Mostly transparent pixels are ignored; a fully transparent image yields an empty string.
+func dominantColor(img *image.NRGBA) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := map[int]*bucket{}
+	var best *bucket
+
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			offset := img.PixOffset(x, y)
+			r, g, b, a := int(img.Pix[offset]), int(img.Pix[offset+1]), int(img.Pix[offset+2]), img.Pix[offset+3]
+			if a < 128 {
+				continue
+			}
+
+			// 4 bits per channel groups visually similar colors
+			key := r>>4<<8 | g>>4<<4 | b>>4
+			entry, ok := buckets[key]
+			if !ok {
+				entry = &bucket{}
+				buckets[key] = entry
+			}
+			entry.count++
+			entry.r += r
+			entry.g += g
+			entry.b += b
+			if best == nil || entry.count > best.count {
+				best = entry
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+// encode83 writes value as length base83 digits
+func encode83(value, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = base83Alphabet[value%83]
+		value /= 83
+	}
+	return string(digits)
+}
+
+// srgbToLinear converts an 8-bit sRGB channel to linear light
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts linear light back to an 8-bit sRGB channel
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow raises the magnitude of value to exp, keeping its sign
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
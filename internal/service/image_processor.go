@@ -1,36 +1,400 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
 	"log"
+	"mime"
+	"product-management-system/config"
+	"product-management-system/internal/models"
 	"product-management-system/internal/queue"
+	"product-management-system/internal/storage"
+	"product-management-system/pkg/logger"
+	"slices"
+	"sync"
 	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultImageQuality      = 80
+	defaultImageMaxDimension = 1200
+	defaultDownloadTimeout   = 30 * time.Second
+	defaultMaxDownloadBytes  = 20 << 20
+	defaultImageWorkers      = 4
+	defaultImageJobTimeout   = 2 * time.Minute
 )
 
 // ImageProcessor handles asynchronous image processing tasks
 type ImageProcessor struct {
-	Queue *queue.RabbitMQ
+	Queue    *queue.RabbitMQ
+	Products *ProductService
+	Storage  storage.Storage
+	Config   config.ImageProcessingConfig
+	fetcher  *imageFetcher
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
 }
 
 // NewImageProcessor creates a new ImageProcessor instance
-func NewImageProcessor(queue *queue.RabbitMQ) *ImageProcessor {
+func NewImageProcessor(queue *queue.RabbitMQ, products *ProductService, store storage.Storage, config config.ImageProcessingConfig) *ImageProcessor {
+	if config.Quality <= 0 || config.Quality > 100 {
+		config.Quality = defaultImageQuality
+	}
+	if config.MaxWidth <= 0 {
+		config.MaxWidth = defaultImageMaxDimension
+	}
+	if config.MaxHeight <= 0 {
+		config.MaxHeight = defaultImageMaxDimension
+	}
+	if config.DownloadTimeout <= 0 {
+		config.DownloadTimeout = defaultDownloadTimeout
+	}
+	if config.MaxDownloadBytes <= 0 {
+		config.MaxDownloadBytes = defaultMaxDownloadBytes
+	}
+	if config.MaxSourcePixels <= 0 {
+		config.MaxSourcePixels = defaultMaxSourcePixels
+	}
+	if len(config.AllowedSchemes) == 0 {
+		config.AllowedSchemes = defaultAllowedSchemes
+	}
+	if config.MaxRedirects <= 0 {
+		config.MaxRedirects = defaultMaxRedirects
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultImageWorkers
+	}
+	if config.JobTimeout <= 0 {
+		config.JobTimeout = defaultImageJobTimeout
+	}
+	config.Variants = normalizeVariants(config)
+
 	return &ImageProcessor{
-		Queue: queue,
+		Queue:    queue,
+		Products: products,
+		Storage:  store,
+		Config:   config,
+		fetcher:  newImageFetcher(config),
 	}
 }
 
-// ConsumeImageProcessingQueue starts consuming messages from the queue
+// normalizeVariants fills in the default variant set and qualities, dropping unnamed,
+// reserved or duplicate entries
+func normalizeVariants(cfg config.ImageProcessingConfig) []config.ImageVariantSpec {
+	specs := cfg.Variants
+	if len(specs) == 0 {
+		specs = []config.ImageVariantSpec{
+			{Name: "thumb", MaxWidth: 150, MaxHeight: 150},
+			{Name: "card", MaxWidth: 400, MaxHeight: 400},
+			{Name: "detail", MaxWidth: cfg.MaxWidth, MaxHeight: cfg.MaxHeight},
+		}
+	}
+
+	normalized := make([]config.ImageVariantSpec, 0, len(specs))
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" || spec.Name == models.OriginalVariant || seen[spec.Name] {
+			log.Printf("Ignoring invalid image variant %q", spec.Name)
+			continue
+		}
+		seen[spec.Name] = true
+		if spec.Quality <= 0 || spec.Quality > 100 {
+			spec.Quality = cfg.Quality
+		}
+		normalized = append(normalized, spec)
+	}
+	return normalized
+}
+
+// variantSpecs returns the configured variants matching names, or all of them when names is empty
+func (p *ImageProcessor) variantSpecs(names []string) ([]config.ImageVariantSpec, error) {
+	if len(names) == 0 {
+		return p.Config.Variants, nil
+	}
+
+	specs := make([]config.ImageVariantSpec, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(p.Config.Variants, func(spec config.ImageVariantSpec) bool { return spec.Name == name })
+		if index < 0 {
+			return nil, fmt.Errorf("%w: unknown image variant %q", queue.ErrMalformedJob, name)
+		}
+		specs = append(specs, p.Config.Variants[index])
+	}
+	return specs, nil
+}
+
+// ConsumeImageProcessingQueue starts a single consumer feeding a pool of Config.Workers workers.
+// The broker prefetch matches the pool size, so each worker has at most one job in hand.
 func (p *ImageProcessor) ConsumeImageProcessingQueue() {
-	go func() {
-		for {
-			msg, ok := <-p.Queue.ConsumeMessages()
-			if !ok {
-				log.Println("Queue closed or stopped")
-				break
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	deliveries := p.Queue.ConsumeMessages(ctx, p.Config.Workers)
+	for i := 0; i < p.Config.Workers; i++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for msg := range deliveries {
+				p.handleMessage(msg)
 			}
+		}()
+	}
+}
 
-			// Simulate image processing (placeholder logic)
-			log.Printf("Processing image: %s", msg)
-			time.Sleep(2 * time.Second) // Simulate processing time
-		}
+// Shutdown stops consuming new jobs and waits for in-flight jobs to finish, or for ctx to expire
+func (p *ImageProcessor) Shutdown(ctx context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(drained)
 	}()
+
+	select {
+	case <-drained:
+		log.Println("Image processing workers drained")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleMessage decodes and processes a single queued job, then acks, retries or dead-letters it
+func (p *ImageProcessor) handleMessage(msg queue.Delivery) {
+	job, err := queue.DecodeJob(msg.Body())
+	if err == nil && job.Type != queue.JobTypeProcessImage && job.Type != queue.JobTypeReprocessImage {
+		err = fmt.Errorf("%w: unsupported job type %q", queue.ErrMalformedJob, job.Type)
+	}
+	if err == nil {
+		_, err = p.variantSpecs(job.Variants)
+	}
+	if err != nil {
+		// Malformed payloads will never succeed, so skip the retries
+		logger.Log.WithError(err).Error("Dead-lettering malformed image job")
+		if err := msg.DeadLetter(err); err != nil {
+			logger.Log.WithError(err).Error("Failed to dead-letter image job")
+		}
+		return
+	}
+
+	fields := logrus.Fields{
+		"job_id":         job.JobID,
+		"job_type":       job.Type,
+		"correlation_id": job.CorrelationID,
+		"product_id":     job.ProductID,
+		"image_index":    job.ImageIndex,
+		"attempt":        msg.RetryCount() + 1,
+	}
+
+	// Status tracking is best effort; a database outage surfaces when the result is recorded
+	current, err := p.Products.MarkImageProcessing(job.ProductID, job.ImageIndex, job.ImageURL)
+	switch {
+	case errors.Is(err, ErrProductNotFound) || (err == nil && !current):
+		logger.Log.WithFields(fields).Info("Skipping stale image job")
+		if err := msg.Ack(); err != nil {
+			logger.Log.WithError(err).WithFields(fields).Error("Failed to ack image job")
+		}
+		return
+	case err != nil:
+		logger.Log.WithError(err).WithFields(fields).Warn("Failed to record image processing status")
+	}
+
+	// Jobs get their own deadline, independent of shutdown, so in-flight work can finish
+	ctx, cancel := context.WithTimeout(context.Background(), p.Config.JobTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err = p.ProcessImage(ctx, job)
+	logger.LogImageProcessingEvent(job.ImageURL, err == nil)
+
+	fields["duration"] = time.Since(start)
+	if err != nil {
+		logger.Log.WithError(err).WithFields(fields).Error("Image processing failed")
+
+		// Rejected sources fail the same way every time, so skip the retries
+		rejected := errors.Is(err, ErrImageRejected)
+		if _, statusErr := p.Products.MarkImageFailed(job.ProductID, job.ImageIndex, job.ImageURL, err, rejected || msg.FinalAttempt()); statusErr != nil {
+			logger.Log.WithError(statusErr).WithFields(fields).Warn("Failed to record image processing status")
+		}
+
+		settle := msg.Retry
+		if rejected {
+			settle = msg.DeadLetter
+		}
+		if err := settle(err); err != nil {
+			logger.Log.WithError(err).WithFields(fields).Error("Failed to settle failed image job")
+		}
+		return
+	}
+
+	logger.Log.WithFields(fields).Info("Image processed")
+	if err := msg.Ack(); err != nil {
+		logger.Log.WithError(err).WithFields(fields).Error("Failed to ack image job")
+	}
+}
+
+// ProcessImage downloads one product image, stores each requested variant, then records the
+// variants on the product and marks the image succeeded. A job without variant names renders all of them.
+func (p *ImageProcessor) ProcessImage(ctx context.Context, job queue.Job) error {
+	specs, err := p.variantSpecs(job.Variants)
+	if err != nil {
+		return err
+	}
+
+	data, err := p.fetchSource(ctx, job.ImageURL)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyImage(data, p.Config.MaxSourcePixels); err != nil {
+		return err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Phones store pixels sideways and rely on the orientation tag, which the variants will not carry
+	meta := readImageMetadata(data)
+	img = applyOrientation(img, meta.Orientation)
+	embed := imageMetadata{}
+	if p.Config.PreserveCopyright {
+		embed = imageMetadata{Artist: meta.Artist, Copyright: meta.Copyright}
+	}
+
+	original := models.ImageVariant{
+		URL:    job.ImageURL,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Bytes:  int64(len(data)),
+		Format: format,
+	}
+	hash := differenceHash(img)
+	contentHash := sourceContentHash(data)
+	placeholder := computePlaceholder(img)
+	result := models.ProcessedImage{Hash: hash, ContentHash: contentHash, Placeholder: placeholder}
+
+	// A full render of a file the product already has can reuse that image's variants.
+	// Variants embedding artist and copyright carry the other image's fields, so they are only shared when none are embedded.
+	if len(job.Variants) == 0 && !p.Config.PreserveCopyright {
+		variants, reused, err := p.reusableVariants(job, contentHash)
+		if err != nil {
+			return err
+		}
+		if reused {
+			variants[models.OriginalVariant] = original
+			result.Variants = variants
+			return p.recordResult(ctx, job, result, nil)
+		}
+	}
+
+	variants := models.ImageVariants{models.OriginalVariant: original}
+	keys := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		rendered, err := renderVariant(img, spec, embed)
+		if err != nil {
+			p.deleteObjects(ctx, keys)
+			return fmt.Errorf("failed to render %s variant: %w", spec.Name, err)
+		}
+
+		key := productObjectPrefix(job.ProductID) + fmt.Sprintf("%d-%s-%s%s", job.ImageIndex, job.JobID, spec.Name, rendered.ext)
+		err = p.Storage.Put(ctx, key, bytes.NewReader(rendered.data), int64(len(rendered.data)), mime.TypeByExtension(rendered.ext))
+		if err != nil {
+			p.deleteObjects(ctx, keys)
+			return fmt.Errorf("failed to store %s variant: %w", spec.Name, err)
+		}
+		keys = append(keys, key)
+
+		variants[spec.Name] = models.ImageVariant{
+			URL:    p.Storage.URL(key),
+			Width:  rendered.width,
+			Height: rendered.height,
+			Bytes:  int64(len(rendered.data)),
+			Format: rendered.format,
+		}
+	}
+
+	result.Variants = variants
+	return p.recordResult(ctx, job, result, keys)
+}
+
+// reusableVariants looks for another image of the job's product with the same source bytes
+// whose variants cover every configured variant, and returns a copy of them
+func (p *ImageProcessor) reusableVariants(job queue.Job, contentHash string) (models.ImageVariants, bool, error) {
+	existing, found, err := p.Products.DuplicateImageVariants(job.ProductID, job.ImageIndex, contentHash)
+	if err != nil && !errors.Is(err, ErrProductNotFound) {
+		return nil, false, fmt.Errorf("failed to look up duplicate images: %w", err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	for _, spec := range p.Config.Variants {
+		if _, ok := existing[spec.Name]; !ok {
+			return nil, false, nil
+		}
+	}
+
+	variants := make(models.ImageVariants, len(existing))
+	for name, variant := range existing {
+		variants[name] = variant
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"job_id":       job.JobID,
+		"product_id":   job.ProductID,
+		"image_index":  job.ImageIndex,
+		"content_hash": contentHash,
+	}).Info("Reusing variants of duplicate image")
+	return variants, true, nil
+}
+
+// recordResult stores the processing result of a job's image on its product. keys are the objects
+// the job stored, which are deleted again if they end up unreferenced.
+func (p *ImageProcessor) recordResult(ctx context.Context, job queue.Job, result models.ProcessedImage, keys []string) error {
+	updated, err := p.Products.SetProcessedImage(job.ProductID, job.ImageIndex, job.ImageURL, result)
+	if err != nil && !errors.Is(err, ErrProductNotFound) {
+		p.deleteObjects(ctx, keys)
+		return fmt.Errorf("failed to record image variants: %w", err)
+	}
+	if !updated {
+		// The product was deleted or its images replaced while the job was queued
+		logger.Log.WithFields(logrus.Fields{
+			"job_id":     job.JobID,
+			"product_id": job.ProductID,
+		}).Info("Discarding stale image job")
+		p.deleteObjects(ctx, keys)
+	}
+	return nil
+}
+
+// deleteObjects removes stored variants that will never be referenced, logging failures
+func (p *ImageProcessor) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := p.Storage.Delete(ctx, key); err != nil {
+			logger.Log.WithError(err).WithField("key", key).Warn("Failed to delete unreferenced image")
+		}
+	}
+}
+
+// fetchSource reads a job's source image, straight from storage when it was uploaded to us
+func (p *ImageProcessor) fetchSource(ctx context.Context, imageURL string) ([]byte, error) {
+	key, ok := storage.KeyFromURL(p.Storage, imageURL)
+	if !ok {
+		return p.fetcher.Fetch(ctx, imageURL)
+	}
+
+	object, err := p.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored image: %w", err)
+	}
+	defer object.Close()
+	return readImageData(object, p.Config.MaxDownloadBytes)
 }
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"product-management-system/config"
+	"product-management-system/internal/models"
+	"product-management-system/internal/storage"
+	"product-management-system/pkg/logger"
+)
+
+// ErrInvalidImage wraps uploads that are not an acceptable image
+var ErrInvalidImage = errors.New("invalid image")
+
+// ErrImageTooLarge is returned for uploads over the configured byte limit
+var ErrImageTooLarge = errors.New("image too large")
+
+const (
+	defaultUploadMaxBytes     = 10 << 20
+	defaultUploadMaxDimension = 8000
+	defaultUploadMaxFiles     = 10
+)
+
+// uploadExtensions maps the sniffed content types accepted for upload to their file extension
+var uploadExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ImageUploader validates uploaded images, stores them as originals and attaches them to products.
+// PreserveCopyright mirrors image_processing.preserve_copyright, so originals keep the same metadata as variants.
+type ImageUploader struct {
+	Products          *ProductService
+	Storage           storage.Storage
+	Config            config.ImageUploadConfig
+	PreserveCopyright bool
+}
+
+// NewImageUploader creates a new ImageUploader
+func NewImageUploader(products *ProductService, store storage.Storage, config config.ImageUploadConfig, preserveCopyright bool) *ImageUploader {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaultUploadMaxBytes
+	}
+	if config.MaxWidth <= 0 {
+		config.MaxWidth = defaultUploadMaxDimension
+	}
+	if config.MaxHeight <= 0 {
+		config.MaxHeight = defaultUploadMaxDimension
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = defaultUploadMaxFiles
+	}
+	return &ImageUploader{Products: products, Storage: store, Config: config, PreserveCopyright: preserveCopyright}
+}
+
+// UploadImages appends uploaded images to a product owned by userID and queues their processing.
+// Every file is validated before anything is stored, so a bad file rejects the whole upload.
+func (u *ImageUploader) UploadImages(ctx context.Context, productID, userID uint, files []io.Reader) (*models.Product, error) {
+	if len(files) > u.Config.MaxFiles {
+		return nil, fmt.Errorf("%w: at most %d files per upload", ErrInvalidImage, u.Config.MaxFiles)
+	}
+
+	// Check ownership up front rather than storing objects that would be thrown away
+	if _, err := u.Products.getOwnedProduct(productID, userID); err != nil {
+		return nil, err
+	}
+
+	uploads := make([][]byte, len(files))
+	extensions := make([]string, len(files))
+	for i, file := range files {
+		data, ext, err := u.readImage(file, u.PreserveCopyright)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %w", i+1, err)
+		}
+		uploads[i], extensions[i] = data, ext
+	}
+
+	keys := make([]string, 0, len(uploads))
+	imageURLs := make([]string, 0, len(uploads))
+	for i, data := range uploads {
+		name, err := newRandomID()
+		if err != nil {
+			u.deleteObjects(ctx, keys)
+			return nil, err
+		}
+
+		ext := extensions[i]
+		key := productObjectPrefix(productID) + fmt.Sprintf("originals/%s%s", name, ext)
+		if err := u.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), http.DetectContentType(data)); err != nil {
+			u.deleteObjects(ctx, keys)
+			return nil, fmt.Errorf("failed to store image: %w", err)
+		}
+		keys = append(keys, key)
+		imageURLs = append(imageURLs, u.Storage.URL(key))
+	}
+
+	product, err := u.Products.AppendImages(productID, userID, imageURLs)
+	if err != nil {
+		u.deleteObjects(ctx, keys)
+		return nil, err
+	}
+	return product, nil
+}
+
+// readImage reads one upload, checks its size, sniffed content type and dimensions, and strips its metadata
+func (u *ImageUploader) readImage(file io.Reader, preserveCopyright bool) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(file, u.Config.MaxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if int64(len(data)) > u.Config.MaxBytes {
+		return nil, "", fmt.Errorf("%w: exceeds %d bytes", ErrImageTooLarge, u.Config.MaxBytes)
+	}
+
+	// Trust the magic bytes, not the client's filename or Content-Type
+	contentType := http.DetectContentType(data)
+	ext, ok := uploadExtensions[contentType]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: unsupported content type %s", ErrInvalidImage, contentType)
+	}
+
+	// DecodeConfig reads only the header, so oversized images are refused before decoding pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width > u.Config.MaxWidth || config.Height > u.Config.MaxHeight {
+		return nil, "", fmt.Errorf("%w: %dx%d exceeds %dx%d", ErrInvalidImage,
+			config.Width, config.Height, u.Config.MaxWidth, u.Config.MaxHeight)
+	}
+
+	// Originals are publicly readable, so GPS and device details must not reach storage
+	data, err = sanitizeOriginal(data, preserveCopyright)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return data, ext, nil
+}
+
+// deleteObjects removes stored originals that will never be referenced, logging failures
+func (u *ImageUploader) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := u.Storage.Delete(ctx, key); err != nil {
+			logger.Log.WithError(err).WithField("key", key).Warn("Failed to delete unreferenced image")
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"product-management-system/config"
+	"product-management-system/internal/models"
+	"product-management-system/internal/queue"
+	"product-management-system/internal/repository"
+	"product-management-system/pkg/logger"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOutboxPollInterval   = time.Second
+	defaultOutboxBatchSize      = 100
+	defaultOutboxRetention      = 7 * 24 * time.Hour
+	defaultOutboxPublishTimeout = 30 * time.Second
+)
+
+// OutboxRelay publishes outbox messages to RabbitMQ, giving at-least-once delivery of jobs
+// written alongside product changes
+type OutboxRelay struct {
+	Repo   repository.OutboxRepository
+	Queue  *queue.RabbitMQ
+	Config config.OutboxConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewOutboxRelay creates a new OutboxRelay
+func NewOutboxRelay(repo repository.OutboxRepository, queue *queue.RabbitMQ, config config.OutboxConfig) *OutboxRelay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultOutboxPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultOutboxBatchSize
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaultOutboxRetention
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultOutboxPublishTimeout
+	}
+	return &OutboxRelay{Repo: repo, Queue: queue, Config: config}
+}
+
+// Start runs the relay loop in the background until Shutdown
+func (r *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		lastCleanup := time.Time{}
+		for {
+			relayed, err := r.relayBatch(ctx)
+			if err != nil && !errors.Is(err, queue.ErrNotConnected) && ctx.Err() == nil {
+				logger.Log.WithError(err).Error("Failed to relay outbox messages")
+			}
+
+			if time.Since(lastCleanup) > time.Hour {
+				r.cleanup()
+				lastCleanup = time.Now()
+			}
+
+			// A full batch means more is probably waiting
+			if relayed == r.Config.BatchSize {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.Config.PollInterval):
+			}
+		}
+	}()
+}
+
+// Shutdown stops the relay, waiting for an in-progress batch to finish or ctx to expire
+func (r *OutboxRelay) Shutdown(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	select {
+	case <-r.done:
+		log.Println("Outbox relay stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// relayBatch publishes one batch of pending messages and marks the confirmed ones as sent.
+// Rows stay locked until the transaction commits, so a crash before marking republishes them.
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	// Leave rows untouched while the broker is away instead of piling up failed attempts
+	if r.Queue.State() != queue.StateConnected {
+		return 0, queue.ErrNotConnected
+	}
+
+	relayed := 0
+	err := r.Repo.Transaction(func(tx *repository.OutboxRepository) error {
+		messages, err := tx.ClaimPending(r.Config.BatchSize)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		jobs := make([]queue.Job, 0, len(messages))
+		pending := make([]models.OutboxMessage, 0, len(messages))
+		var discarded []uint
+		for _, message := range messages {
+			job, err := queue.DecodeJob(message.Payload)
+			if err != nil {
+				// A payload that cannot be decoded will never publish; retire it rather than block the queue
+				logger.Log.WithError(err).WithField("outbox_id", message.ID).Error("Discarding malformed outbox message")
+				if err := tx.RecordFailure(message.ID, err.Error()); err != nil {
+					return err
+				}
+				discarded = append(discarded, message.ID)
+				continue
+			}
+			jobs = append(jobs, job)
+			pending = append(pending, message)
+		}
+		if err := tx.MarkSent(discarded, time.Now()); err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		// The claimed rows stay locked while confirms are awaited, so a stalled broker must not hold them forever
+		publishCtx, cancel := context.WithTimeout(ctx, r.Config.PublishTimeout)
+		acked, err := r.Queue.PublishJobsConfirmed(publishCtx, jobs)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			// Roll back so the rows are released and claimed again by the next poll
+			return fmt.Errorf("waiting for publisher confirms: %w", err)
+		}
+		if err != nil {
+			for _, message := range pending {
+				if err := tx.RecordFailure(message.ID, err.Error()); err != nil {
+					return err
+				}
+			}
+			// Commit the failure counts; the rows remain pending for the next poll
+			return nil
+		}
+
+		var sent []uint
+		for i, message := range pending {
+			if acked[i] {
+				sent = append(sent, message.ID)
+				continue
+			}
+			if err := tx.RecordFailure(message.ID, "broker rejected message"); err != nil {
+				return err
+			}
+		}
+		relayed = len(sent)
+		return tx.MarkSent(sent, time.Now())
+	})
+	return relayed, err
+}
+
+// cleanup removes messages that were sent longer ago than the retention period
+func (r *OutboxRelay) cleanup() {
+	deleted, err := r.Repo.DeleteSentBefore(time.Now().Add(-r.Config.Retention))
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to clean up outbox")
+		return
+	}
+	if deleted > 0 {
+		logger.Log.WithFields(logrus.Fields{"deleted": deleted}).Info("Cleaned up sent outbox messages")
+	}
+}
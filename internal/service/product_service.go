@@ -1,48 +1,777 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"product-management-system/internal/cache"
 	"product-management-system/internal/models"
+	"product-management-system/internal/queue"
 	"product-management-system/internal/repository"
 	"product-management-system/internal/shared"
-)
+	"product-management-system/internal/storage"
+	"product-management-system/pkg/logger"
+	"product-management-system/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"gorm.io/gorm"
+)
 
 var ErrProductNotFound = errors.New("product not found")
 
-// ProductService handles business logic for products
+// ErrProductForbidden is returned when a user modifies a product they do not own
+var ErrProductForbidden = errors.New("product belongs to another user")
+
+// ErrInvalidProduct wraps validation failures on product writes
+var ErrInvalidProduct = errors.New("invalid product")
+
+// ErrInvalidFilter is returned for product listing parameters that cannot be satisfied
+var ErrInvalidFilter = errors.New("invalid product filter")
+
+// FilterProblem classifies why a field of a product filter was rejected
+type FilterProblem string
+
+const (
+	FilterOutOfRange    FilterProblem = "out_of_range"
+	FilterTooLong       FilterProblem = "too_long"
+	FilterInvalidChoice FilterProblem = "invalid_choice"
+	FilterInvalidCursor FilterProblem = "invalid_cursor"
+	FilterInvalidSearch FilterProblem = "invalid_search"
+	FilterConflict      FilterProblem = "conflict"
+)
+
+// FilterError rejects one field of a product filter and wraps ErrInvalidFilter.
+// Field is named after the GET /products parameter that sets it, so handlers can report it as is.
+type FilterError struct {
+	Field   string
+	Problem FilterProblem
+	Message string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidFilter, e.Field, e.Message)
+}
+
+func (e *FilterError) Unwrap() error {
+	return ErrInvalidFilter
+}
+
+const (
+	// DefaultProductPageSize is the page size when the client does not choose one
+	DefaultProductPageSize = 20
+	// MaxProductPageSize bounds a single page so large catalogs cannot be listed in one request
+	MaxProductPageSize = 100
+)
+
+const (
+	defaultProductCacheTTL  = 10 * time.Minute
+	defaultNegativeCacheTTL = 30 * time.Second
+	defaultFacetCacheTTL    = time.Minute
+
+	// productGenerationTTL keeps a product's invalidation counter far longer than any cache fill takes
+	productGenerationTTL = time.Hour
+
+	// objectDeleteTimeout bounds the cleanup of stored images a write left unreferenced
+	objectDeleteTimeout = 30 * time.Second
+)
+
+// priceBucketBounds are the edges of the price facet buckets
+var priceBucketBounds = []float64{10, 25, 50, 100, 250, 500, 1000}
+
+// facetValueLimit caps the values listed per category, tag and seller facet
+const facetValueLimit = 20
+
+// ProductCacheConfig controls how long product lookups and facet counts stay cached
+type ProductCacheConfig struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	// FacetTTL is how stale facet counts may get, since product writes do not invalidate them
+	FacetTTL time.Duration
+}
+
+// ProductService handles business logic for products.
+// Storage holds uploaded originals and rendered variants, which are deleted once no product references them.
 type ProductService struct {
-	Repo repository.ProductRepository
-	Cache cache.RedisCache
+	Repo        repository.ProductRepository
+	Cache       cache.RedisCache
+	CacheConfig ProductCacheConfig
+	Storage     storage.Storage
 }
 
-// ProductFilter represents filtering criteria for listing products
+// SimilarImages lists the images of other products that look like one image of a product.
+// Complete is false when the search was too wide for the band indexes and skipped older images.
+type SimilarImages struct {
+	ImageIndex int                 `json:"image_index"`
+	SourceURL  string              `json:"source_url"`
+	Hash       string              `json:"hash"`
+	Matches    []models.ImageMatch `json:"matches"`
+	Complete   bool                `json:"complete"`
+}
 
+// cachedProduct is the cache entry for a product lookup; Missing marks a negative entry
+type cachedProduct struct {
+	Product *models.Product `json:"product,omitempty"`
+	Missing bool            `json:"missing,omitempty"`
+}
+
+// ProductFilter represents filtering criteria for listing products
 
 // NewProductService creates a new ProductService
-func NewProductService(repo repository.ProductRepository, cache cache.RedisCache) *ProductService {
-	return &ProductService{Repo: repo,Cache: cache}
+func NewProductService(repo repository.ProductRepository, cache cache.RedisCache, cacheConfig ProductCacheConfig, store storage.Storage) *ProductService {
+	if cacheConfig.TTL <= 0 {
+		cacheConfig.TTL = defaultProductCacheTTL
+	}
+	if cacheConfig.NegativeTTL <= 0 {
+		cacheConfig.NegativeTTL = defaultNegativeCacheTTL
+	}
+	if cacheConfig.FacetTTL <= 0 {
+		cacheConfig.FacetTTL = defaultFacetCacheTTL
+	}
+	return &ProductService{Repo: repo, Cache: cache, CacheConfig: cacheConfig, Storage: store}
 }
 
-// CreateProduct adds a new product
+// CreateProduct adds a new product and queues processing of its images in the same transaction
 func (s *ProductService) CreateProduct(product *models.Product) (*models.Product, error) {
-	err := s.Repo.CreateProduct(product)
+	// Processing results come from the image processor, never from clients
+	product.CompressedImages = nil
+	product.ImagePlaceholders = make(models.ImagePlaceholderList, len(product.ProductImages))
+	product.ImageVariants = nil
+	product.ImageStatus = models.NewQueuedImageStatuses(product.ProductImages, time.Now().UTC())
+	// Timestamps are set by the database layer
+	product.CreatedAt = time.Time{}
+	product.UpdatedAt = time.Time{}
+	err := s.Repo.Transaction(func(products *repository.ProductRepository, outbox *repository.OutboxRepository) error {
+		if err := products.CreateProduct(product); err != nil {
+			return err
+		}
+		messages, err := imageJobMessages(product.ID, product.ProductImages, 0)
+		if err != nil {
+			return err
+		}
+		return outbox.Enqueue(messages)
+	})
 	if err != nil {
 		return nil, err
 	}
+	// Drop any negative entry cached while the ID did not exist yet
+	s.InvalidateProduct(product.ID)
 	return product, nil
 }
 
-
 // GetProductByID retrieves a product by its ID
 func (s *ProductService) GetProductByID(id uint) (*models.Product, error) {
-	return s.Repo.GetProductByID(id)
+	product, _, err := s.GetProductByIDCached(id)
+	return product, err
+}
+
+// GetProductByIDCached retrieves a product through the cache and reports whether it was a cache hit
+func (s *ProductService) GetProductByIDCached(id uint) (*models.Product, bool, error) {
+	key := productCacheKey(id)
+
+	// Cache failures fall through to the database
+	var entry cachedProduct
+	found, err := s.Cache.Lookup(key, &entry)
+	if err == nil && found {
+		if entry.Missing || entry.Product == nil {
+			return nil, true, ErrProductNotFound
+		}
+		return entry.Product, true, nil
+	}
+
+	// The generation is read before the database, so a write committing meanwhile bumps it
+	// and the fill below is dropped instead of caching the pre-write row
+	generation, genErr := s.Cache.Generation(productGenerationKey(id))
+
+	product, err := s.loadProduct(id)
+	switch {
+	case errors.Is(err, ErrProductNotFound):
+		if genErr == nil {
+			s.storeCacheEntry(id, cachedProduct{Missing: true}, s.CacheConfig.NegativeTTL, generation)
+		}
+		return nil, false, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	if genErr == nil {
+		s.storeCacheEntry(id, cachedProduct{Product: product}, s.CacheConfig.TTL, generation)
+	}
+	return product, false, nil
+}
+
+// SetProcessedImage records the renditions, placeholder and perceptual hash of one product image,
+// marks it succeeded and invalidates the cached product. It reports false when the job is stale
+// because the product's image list changed since it was queued.
+func (s *ProductService) SetProcessedImage(id uint, index int, sourceURL string, result models.ProcessedImage) (bool, error) {
+	updated, released, err := s.Repo.SetProcessedImage(id, index, sourceURL, result, time.Now().UTC())
+	updated, err = s.afterImageUpdate(id, updated, err)
+	if err == nil {
+		// A reprocessed image's previous renditions are no longer referenced
+		s.deleteReleasedObjects(id, released)
+	}
+	return updated, err
+}
+
+// DuplicateImageVariants returns the variants of another image of the same product whose source bytes
+// have the given SHA-256, so a picture uploaded twice is not rendered and stored again. Only exact copies
+// qualify: perceptually similar images, such as another colour of the same shot, still get their own variants.
+func (s *ProductService) DuplicateImageVariants(id uint, index int, contentHash string) (models.ImageVariants, bool, error) {
+	hashes, err := s.Repo.ImageHashes().ListHashes(id)
+	if err != nil || len(hashes) == 0 {
+		return nil, false, err
+	}
+	product, err := s.loadProduct(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, candidate := range hashes {
+		i := candidate.ImageIndex
+		if i == index || candidate.ContentHash != contentHash || i >= len(product.ProductImages) || i >= len(product.ImageVariants) {
+			continue
+		}
+		// The hash row may describe an image that has since been replaced
+		if product.ProductImages[i] != candidate.SourceURL || len(product.ImageVariants[i]) == 0 {
+			continue
+		}
+		return product.ImageVariants[i], true, nil
+	}
+	return nil, false, nil
+}
+
+// SimilarImages lists, for each hashed image of a product owned by userID, images of other products within maxDistance bits
+func (s *ProductService) SimilarImages(id, userID uint, maxDistance, limit int) ([]SimilarImages, error) {
+	product, err := s.getOwnedProduct(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := s.Repo.ImageHashes().ListHashes(id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SimilarImages, 0, len(hashes))
+	for _, hash := range hashes {
+		i := hash.ImageIndex
+		if i >= len(product.ProductImages) || product.ProductImages[i] != hash.SourceURL {
+			continue
+		}
+
+		matches, complete, err := s.Repo.ImageHashes().FindSimilar(hash.Hash, maxDistance, id, limit)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SimilarImages{
+			ImageIndex: i,
+			SourceURL:  hash.SourceURL,
+			Hash:       formatHash(hash.Hash),
+			Matches:    matches,
+			Complete:   complete,
+		})
+	}
+	return results, nil
+}
+
+// MarkImageProcessing records that a worker started an attempt on one product image.
+// Like SetProcessedImage it reports false when the job is stale.
+func (s *ProductService) MarkImageProcessing(id uint, index int, sourceURL string) (bool, error) {
+	now := time.Now().UTC()
+	updated, err := s.Repo.UpdateImageStatus(id, index, sourceURL, func(status *models.ImageStatus) {
+		status.State = models.ImageStateProcessing
+		status.Attempts++
+		status.StartedAt = &now
+		status.CompletedAt = nil
+		status.UpdatedAt = &now
+	})
+	return s.afterImageUpdate(id, updated, err)
+}
+
+// MarkImageFailed records a failed attempt on one product image. The image goes back to queued
+// while a retry is pending and becomes failed once final is set.
+func (s *ProductService) MarkImageFailed(id uint, index int, sourceURL string, cause error, final bool) (bool, error) {
+	now := time.Now().UTC()
+	updated, err := s.Repo.UpdateImageStatus(id, index, sourceURL, func(status *models.ImageStatus) {
+		status.State = models.ImageStateQueued
+		status.Error = cause.Error()
+		status.UpdatedAt = &now
+		if final {
+			status.State = models.ImageStateFailed
+			status.CompletedAt = &now
+		}
+	})
+	return s.afterImageUpdate(id, updated, err)
+}
+
+// afterImageUpdate maps repository results of per-image updates and invalidates the cached product
+func (s *ProductService) afterImageUpdate(id uint, updated bool, err error) (bool, error) {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrProductNotFound
+		}
+		return false, err
+	}
+	if updated {
+		s.InvalidateProduct(id)
+	}
+	return updated, nil
+}
+
+// InvalidateProduct removes the cached entry for a product and bumps its generation,
+// so lookups that read the database before the write cannot cache what they read
+func (s *ProductService) InvalidateProduct(id uint) {
+	if err := s.Cache.BumpGeneration(productGenerationKey(id), productGenerationTTL); err != nil {
+		logger.Log.WithError(err).WithField("product_id", id).Warn("Failed to bump product cache generation")
+	}
+	if err := s.Cache.Delete(productCacheKey(id)); err != nil {
+		logger.Log.WithError(err).WithField("product_id", id).Warn("Failed to invalidate product cache")
+	}
+}
+
+// ListProducts retrieves one page of a user's products matching the filter
+func (s *ProductService) ListProducts(filter shared.ProductFilter) (*shared.ProductPage, error) {
+	if err := normalizeProductFilter(&filter); err != nil {
+		return nil, err
+	}
+	page, err := s.Repo.ListProducts(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter.Facets) > 0 {
+		if page.Facets, err = s.productFacets(filter); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// productFacets counts the requested facets of a filter, serving them from the cache while fresh.
+// Each facet drops its own filter, so choosing another value of it is counted too.
+func (s *ProductService) productFacets(filter shared.ProductFilter) (*shared.ProductFacets, error) {
+	key := facetCacheKey(filter)
+
+	// Cache failures fall through to the database
+	var facets shared.ProductFacets
+	found, err := s.Cache.Lookup(key, &facets)
+	if err == nil && found {
+		return &facets, nil
+	}
+
+	for _, name := range filter.Facets {
+		facetFilter := filter
+		switch name {
+		case shared.FacetPrice:
+			facetFilter.MinPrice, facetFilter.MaxPrice = 0, 0
+			counts, err := s.Repo.PriceFacet(facetFilter, priceBucketBounds)
+			if err != nil {
+				return nil, err
+			}
+			facets.Price = priceBuckets(counts)
+		case shared.FacetCategory:
+			facetFilter.Category = ""
+			if facets.Category, err = s.Repo.CategoryFacet(facetFilter, facetValueLimit); err != nil {
+				return nil, err
+			}
+		case shared.FacetTag:
+			facetFilter.Tag = ""
+			if facets.Tag, err = s.Repo.TagFacet(facetFilter, facetValueLimit); err != nil {
+				return nil, err
+			}
+		case shared.FacetSeller:
+			facetFilter.UserID = 0
+			if facets.Seller, err = s.Repo.SellerFacet(facetFilter, facetValueLimit); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.Cache.Set(key, facets, s.CacheConfig.FacetTTL); err != nil {
+		logger.Log.WithError(err).WithField("key", key).Warn("Failed to cache product facets")
+	}
+	return &facets, nil
+}
+
+// priceBuckets pairs the counts of PriceFacet with their bucket edges
+func priceBuckets(counts []int64) []shared.PriceBucket {
+	buckets := make([]shared.PriceBucket, len(counts))
+	for i, count := range counts {
+		buckets[i].Count = count
+		if i > 0 {
+			buckets[i].Min = priceBucketBounds[i-1]
+		}
+		if i < len(priceBucketBounds) {
+			max := priceBucketBounds[i]
+			buckets[i].Max = &max
+		}
+	}
+	return buckets
+}
+
+// normalizeProductFilter fills in the default page size and ordering and rejects inconsistent paging.
+// Each rejected field is reported as a *FilterError; several are joined with errors.Join.
+func normalizeProductFilter(filter *shared.ProductFilter) error {
+	var errs []error
+	reject := func(field string, problem FilterProblem, message string) {
+		errs = append(errs, &FilterError{Field: field, Problem: problem, Message: message})
+	}
+
+	if filter.UserID == 0 {
+		reject("user_id", FilterOutOfRange, "is required")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultProductPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > MaxProductPageSize {
+		reject("limit", FilterOutOfRange, fmt.Sprintf("must be between 1 and %d", MaxProductPageSize))
+	}
+	if filter.Offset < 0 {
+		reject("offset", FilterOutOfRange, "must not be negative")
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	if utf8.RuneCountInString(filter.Query) > shared.MaxSearchQueryLength {
+		reject("q", FilterTooLong, fmt.Sprintf("must be at most %d characters", shared.MaxSearchQueryLength))
+	} else if filter.Query != "" && len(shared.SearchTerms(filter.Query)) == 0 {
+		reject("q", FilterInvalidSearch, "must contain at least one word")
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = shared.SortByCreatedAt
+		if filter.Query != "" {
+			filter.Sort = shared.SortByRelevance
+		}
+	case shared.SortByCreatedAt, shared.SortByPrice, shared.SortByName:
+	case shared.SortByRelevance:
+		if filter.Query == "" {
+			reject("sort", FilterConflict, "relevance requires q")
+		}
+	default:
+		reject("sort", FilterInvalidChoice, "must be one of created_at, price, name, relevance")
+	}
+
+	if filter.After != nil {
+		if filter.Offset > 0 {
+			reject("offset", FilterConflict, "cannot be combined with cursor")
+		}
+		if filter.After.Sort != filter.Sort || filter.After.Descending != filter.Descending {
+			reject("cursor", FilterInvalidCursor, "was issued for a different sort or order")
+		}
+	}
+
+	// Facets are kept in their canonical order without repeats, so equal requests share a cache entry
+	requested := make(map[shared.FacetName]bool, len(filter.Facets))
+	for _, name := range filter.Facets {
+		if !slices.Contains(shared.FacetNames, name) {
+			reject("facets", FilterInvalidChoice, "must be a comma-separated list of price, category, tag and seller")
+			break
+		}
+		requested[name] = true
+	}
+	filter.Facets = nil
+	for _, name := range shared.FacetNames {
+		if requested[name] {
+			filter.Facets = append(filter.Facets, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateProduct replaces the editable fields of a product owned by userID
+func (s *ProductService) UpdateProduct(id, userID uint, input *models.Product) (*models.Product, error) {
+	return s.editProduct(id, userID, func(product *models.Product) (*models.Product, error) {
+		// Compressed images are produced by the image processor, never by clients
+		product.ProductName = input.ProductName
+		product.ProductDescription = input.ProductDescription
+		product.ProductImages = input.ProductImages
+		product.ProductPrice = input.ProductPrice
+		product.Category = input.Category
+		product.Tags = input.Tags
+		return product, nil
+	})
+}
+
+// PatchProduct applies a JSON merge patch to a product owned by userID
+func (s *ProductService) PatchProduct(id, userID uint, patch []byte) (*models.Product, error) {
+	return s.editProduct(id, userID, func(product *models.Product) (*models.Product, error) {
+		original, err := json.Marshal(product)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := utils.MergePatch(original, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+		}
+
+		var patched models.Product
+		if err := json.Unmarshal(merged, &patched); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+		}
+
+		// Identity, ownership, timestamps and processing results cannot be patched
+		patched.ID = product.ID
+		patched.UserID = product.UserID
+		patched.CreatedAt = product.CreatedAt
+		patched.CompressedImages = product.CompressedImages
+		patched.ImagePlaceholders = product.ImagePlaceholders
+		patched.ImageVariants = product.ImageVariants
+		patched.ImageStatus = product.ImageStatus
+		return &patched, nil
+	})
+}
+
+// AppendImages adds image URLs to the end of a product owned by userID and queues processing of just those images
+func (s *ProductService) AppendImages(id, userID uint, imageURLs []string) (*models.Product, error) {
+	var product *models.Product
+	err := s.Repo.Transaction(func(products *repository.ProductRepository, outbox *repository.OutboxRepository) error {
+		// Lock the row so concurrent appends cannot claim the same image indexes
+		locked, err := lockOwnedProduct(products, id, userID)
+		if err != nil {
+			return err
+		}
+
+		firstIndex := len(locked.ProductImages)
+		locked.ImageStatus = append(locked.AlignedImageStatus(), models.NewQueuedImageStatuses(imageURLs, time.Now().UTC())...)
+		locked.ProductImages = append(locked.ProductImages, imageURLs...)
+		locked.ImagePlaceholders = locked.AlignedPlaceholders()
+		if err := products.UpdateProduct(locked); err != nil {
+			return err
+		}
+
+		messages, err := imageJobMessages(locked.ID, imageURLs, firstIndex)
+		if err != nil {
+			return err
+		}
+		product = locked
+		return outbox.Enqueue(messages)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateProduct(id)
+	return product, nil
+}
+
+// DeleteProduct removes a product owned by userID.
+// Ownership is checked under the row lock, so the product cannot change hands between the check and the delete.
+func (s *ProductService) DeleteProduct(id, userID uint) error {
+	var released []string
+	err := s.Repo.Transaction(func(products *repository.ProductRepository, _ *repository.OutboxRepository) error {
+		product, err := lockOwnedProduct(products, id, userID)
+		if err != nil {
+			return err
+		}
+		released = models.ReleasedURLs(product.ReferencedURLs(), nil)
+		return products.DeleteProduct(id)
+	})
+	if err != nil {
+		return err
+	}
+	s.InvalidateProduct(id)
+	s.deleteReleasedObjects(id, released)
+	return nil
+}
+
+// loadProduct reads a product straight from the database
+func (s *ProductService) loadProduct(id uint) (*models.Product, error) {
+	product, err := s.Repo.GetProductByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return product, nil
 }
 
-// ListProducts retrieves all products for a user with optional filters
-func (s *ProductService) ListProducts(filter shared.ProductFilter) ([]models.Product, error) {
-	return s.Repo.ListProducts(filter)
+// getOwnedProduct loads a product and verifies it belongs to userID
+func (s *ProductService) getOwnedProduct(id, userID uint) (*models.Product, error) {
+	// Writes always start from the database rather than a possibly stale cache entry
+	product, err := s.loadProduct(id)
+	if err != nil {
+		return nil, err
+	}
+	if product.UserID != userID {
+		return nil, ErrProductForbidden
+	}
+	return product, nil
 }
 
+// lockOwnedProduct reads a product under a row lock held until the transaction ends and verifies it belongs to userID
+func lockOwnedProduct(products *repository.ProductRepository, id, userID uint) (*models.Product, error) {
+	product, err := products.GetProductForUpdate(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	if product.UserID != userID {
+		return nil, ErrProductForbidden
+	}
+	return product, nil
+}
 
+// editProduct applies edit to a product owned by userID and persists the result, reprocessing its images when the list changed.
+// The row is read and written under a lock in one transaction, so image processing results committed
+// by workers meanwhile are never overwritten with the stale copy.
+func (s *ProductService) editProduct(id, userID uint, edit func(product *models.Product) (*models.Product, error)) (*models.Product, error) {
+	var product *models.Product
+	var released []string
+	err := s.Repo.Transaction(func(products *repository.ProductRepository, outbox *repository.OutboxRepository) error {
+		locked, err := lockOwnedProduct(products, id, userID)
+		if err != nil {
+			return err
+		}
+		originalImages := slices.Clone(locked.ProductImages)
+		originalURLs := locked.ReferencedURLs()
+
+		edited, err := edit(locked)
+		if err != nil {
+			return err
+		}
+		if err := utils.ValidateProductUpdate(*edited); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+		}
+
+		// Processing results of the old list no longer line up with the new one
+		imagesChanged := !slices.Equal(originalImages, edited.ProductImages)
+		if imagesChanged {
+			edited.CompressedImages = nil
+			edited.ImagePlaceholders = make(models.ImagePlaceholderList, len(edited.ProductImages))
+			edited.ImageVariants = nil
+			edited.ImageStatus = models.NewQueuedImageStatuses(edited.ProductImages, time.Now().UTC())
+		}
+		if err := products.UpdateProduct(edited); err != nil {
+			return err
+		}
+		product = edited
+		released = models.ReleasedURLs(originalURLs, edited)
+		if !imagesChanged {
+			return nil
+		}
+		messages, err := imageJobMessages(edited.ID, edited.ProductImages, 0)
+		if err != nil {
+			return err
+		}
+		return outbox.Enqueue(messages)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateProduct(id)
+	s.deleteReleasedObjects(id, released)
+	return product, nil
+}
+
+// deleteReleasedObjects removes stored images a committed write left unreferenced, so they stop being served.
+// Only keys under the product's own prefix are deleted: clients may point a product at any URL,
+// including another product's images, and those must survive.
+func (s *ProductService) deleteReleasedObjects(id uint, urls []string) {
+	if s.Storage == nil || len(urls) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), objectDeleteTimeout)
+	defer cancel()
+	prefix := productObjectPrefix(id)
+	for _, url := range urls {
+		key, ok := storage.KeyFromURL(s.Storage, url)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			logger.Log.WithError(err).WithField("key", key).Warn("Failed to delete unreferenced image")
+		}
+	}
+}
+
+// imageJobMessages builds one outbox message per image URL, sharing a correlation ID.
+// The first URL sits at firstIndex in the product's image list.
+func imageJobMessages(productID uint, imageURLs []string, firstIndex int) ([]models.OutboxMessage, error) {
+	if len(imageURLs) == 0 {
+		return nil, nil
+	}
+
+	correlationID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]models.OutboxMessage, 0, len(imageURLs))
+	for offset, imageURL := range imageURLs {
+		jobID, err := newRandomID()
+		if err != nil {
+			return nil, err
+		}
+
+		payload, err := queue.EncodeJob(queue.Job{
+			JobID:         jobID,
+			Type:          queue.JobTypeProcessImage,
+			CorrelationID: correlationID,
+			ProductID:     productID,
+			ImageIndex:    firstIndex + offset,
+			ImageURL:      imageURL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, models.OutboxMessage{MessageID: jobID, Payload: payload})
+	}
+	return messages, nil
+}
+
+// storeCacheEntry writes a product lookup result to the cache unless the product was invalidated
+// since generation was read, logging failures
+func (s *ProductService) storeCacheEntry(id uint, entry cachedProduct, ttl time.Duration, generation int64) {
+	key := productCacheKey(id)
+	if _, err := s.Cache.SetIfGeneration(key, entry, ttl, productGenerationKey(id), generation); err != nil {
+		logger.Log.WithError(err).WithField("key", key).Warn("Failed to cache product")
+	}
+}
+
+// facetCacheKey identifies facet counts by the filter fields that change them, so paging and sorting share entries.
+// Names are matched case-insensitively and queries by their search terms, so those are normalized the same way.
+func facetCacheKey(filter shared.ProductFilter) string {
+	key, _ := json.Marshal(struct {
+		UserID      uint
+		MinPrice    float64
+		MaxPrice    float64
+		ProductName string
+		Query       string
+		Category    string
+		Tag         string
+		Facets      []shared.FacetName
+	}{
+		UserID:      filter.UserID,
+		MinPrice:    filter.MinPrice,
+		MaxPrice:    filter.MaxPrice,
+		ProductName: strings.ToLower(filter.ProductName),
+		Query:       strings.Join(shared.SearchTerms(filter.Query), " "),
+		Category:    filter.Category,
+		Tag:         filter.Tag,
+		Facets:      filter.Facets,
+	})
+	sum := sha256.Sum256(key)
+	return "product_facets:" + hex.EncodeToString(sum[:])
+}
+
+// productObjectPrefix is the storage key prefix of every object stored for a product
+func productObjectPrefix(id uint) string {
+	return fmt.Sprintf("products/%d/", id)
+}
+
+// productCacheKey returns the cache key for a single product
+func productCacheKey(id uint) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// productGenerationKey returns the key of a product's invalidation counter
+func productGenerationKey(id uint) string {
+	return fmt.Sprintf("product_generation:%d", id)
+}
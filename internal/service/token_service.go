@@ -0,0 +1,262 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"product-management-system/internal/cache"
+	"product-management-system/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// minSigningKeyLength is the shortest HMAC key accepted, matching the SHA-256 output size
+	minSigningKeyLength = 32
+	// sampleSigningKey is the placeholder shipped in configs/config.yaml, which must never sign real tokens
+	sampleSigningKey = "change-me-to-a-long-random-secret"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, revoked or of the wrong type
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrTokenNotOwned is returned when a caller revokes a token issued to another user
+var ErrTokenNotOwned = errors.New("token belongs to another user")
+
+// TokenConfig holds the signing keys and lifetimes used for issued tokens
+type TokenConfig struct {
+	Issuer          string
+	ActiveKeyID     string
+	Keys            map[string]string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// TokenPair is returned to clients after login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// TokenClaims are the claims carried by access and refresh tokens
+type TokenClaims struct {
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the user the token was issued to
+func (c *TokenClaims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// TokenStore keeps the active refresh tokens and revoked access tokens; *cache.RedisCache implements it
+type TokenStore interface {
+	Set(key string, value interface{}, expiration time.Duration) error
+	Take(key string) (bool, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+}
+
+var _ TokenStore = (*cache.RedisCache)(nil)
+
+// TokenService issues, verifies and revokes JWT access and refresh tokens
+type TokenService struct {
+	Cache  TokenStore
+	Config TokenConfig
+}
+
+// NewTokenService creates a new TokenService
+func NewTokenService(cache TokenStore, config TokenConfig) (*TokenService, error) {
+	if _, ok := config.Keys[config.ActiveKeyID]; !ok {
+		return nil, fmt.Errorf("active signing key %q is not in the key set", config.ActiveKeyID)
+	}
+	// Every key still verifies tokens, so a weak retired key is as dangerous as a weak active one
+	for id, key := range config.Keys {
+		if key == sampleSigningKey {
+			return nil, fmt.Errorf("signing key %q is the sample value; set a random secret in the config or AUTH_SIGNING_KEYS", id)
+		}
+		if len(key) < minSigningKeyLength {
+			return nil, fmt.Errorf("signing key %q is shorter than %d bytes", id, minSigningKeyLength)
+		}
+	}
+	if config.AccessTokenTTL <= 0 {
+		config.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if config.RefreshTokenTTL <= 0 {
+		config.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	return &TokenService{Cache: cache, Config: config}, nil
+}
+
+// IssueTokens creates a new access token and refresh token for a user
+func (s *TokenService) IssueTokens(user *models.User) (*TokenPair, error) {
+	now := time.Now()
+
+	accessToken, _, err := s.sign(user.ID, accessTokenType, now, s.Config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, refreshID, err := s.sign(user.ID, refreshTokenType, now, s.Config.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens are only valid while Redis remembers them, which makes rotation and logout possible
+	if err := s.Cache.Set(refreshTokenKey(refreshID), user.ID, s.Config.RefreshTokenTTL); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.Config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, invalidating the old refresh token
+func (s *TokenService) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := s.parse(refreshToken, refreshTokenType)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Consuming the key makes each refresh token single-use, even under concurrent requests
+	active, err := s.Cache.Take(refreshTokenKey(claims.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrInvalidToken
+	}
+
+	return s.IssueTokens(&models.User{ID: userID})
+}
+
+// ValidateAccessToken verifies an access token and checks it has not been revoked
+func (s *TokenService) ValidateAccessToken(accessToken string) (*TokenClaims, error) {
+	claims, err := s.parse(accessToken, accessTokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Cache.Exists(revokedTokenKey(claims.ID))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// RevokeAccessToken denies an access token until it would have expired anyway
+func (s *TokenService) RevokeAccessToken(claims *TokenClaims) error {
+	remaining := time.Until(claims.ExpiresAt.Time)
+	if remaining <= 0 {
+		return nil
+	}
+	return s.Cache.Set(revokedTokenKey(claims.ID), true, remaining)
+}
+
+// RevokeRefreshToken invalidates a refresh token of userID so it can no longer be exchanged
+func (s *TokenService) RevokeRefreshToken(refreshToken string, userID uint) error {
+	claims, err := s.parse(refreshToken, refreshTokenType)
+	if err != nil {
+		return err
+	}
+	subject, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+	if subject != userID {
+		return ErrTokenNotOwned
+	}
+	return s.Cache.Delete(refreshTokenKey(claims.ID))
+}
+
+// sign creates a token of the given type signed with the active key
+func (s *TokenService) sign(userID uint, tokenType string, now time.Time, ttl time.Duration) (string, string, error) {
+	tokenID, err := newRandomID()
+	if err != nil {
+		return "", "", err
+	}
+
+	claims := TokenClaims{
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    s.Config.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = s.Config.ActiveKeyID
+	signed, err := token.SignedString([]byte(s.Config.Keys[s.Config.ActiveKeyID]))
+	if err != nil {
+		return "", "", err
+	}
+	return signed, tokenID, nil
+}
+
+// parse verifies a token's signature, expiry, issuer and type, selecting the key by its kid header
+func (s *TokenService) parse(tokenString, tokenType string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := s.Config.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.Config.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Type != tokenType || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// newRandomID generates a random hex identifier, used for token jti claims and job IDs
+func newRandomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// refreshTokenKey returns the cache key marking a refresh token as active
+func refreshTokenKey(tokenID string) string {
+	return "auth:refresh:" + tokenID
+}
+
+// revokedTokenKey returns the cache key marking an access token as revoked
+func revokedTokenKey(tokenID string) string {
+	return "auth:revoked:" + tokenID
+}
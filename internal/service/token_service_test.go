@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"product-management-system/internal/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+// memoryTokenStore is an in-memory TokenStore; expirations are ignored since tests finish well before them
+type memoryTokenStore struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{keys: make(map[string]bool)}
+}
+
+func (m *memoryTokenStore) Set(key string, _ interface{}, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = true
+	return nil
+}
+
+func (m *memoryTokenStore) Take(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := m.keys[key]
+	delete(m.keys, key)
+	return found, nil
+}
+
+func (m *memoryTokenStore) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys[key], nil
+}
+
+func (m *memoryTokenStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// newTestTokenService creates a TokenService signing with testSigningKey under kid "k1"
+func newTestTokenService(t *testing.T, issuer string) *TokenService {
+	t.Helper()
+	tokens, err := NewTokenService(newMemoryTokenStore(), TokenConfig{
+		Issuer:      issuer,
+		ActiveKeyID: "k1",
+		Keys:        map[string]string{"k1": testSigningKey},
+	})
+	if err != nil {
+		t.Fatalf("NewTokenService: %v", err)
+	}
+	return tokens
+}
+
+// signTestToken signs arbitrary claims with testSigningKey under the given kid
+func signTestToken(t *testing.T, kid string, claims TokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString([]byte(testSigningKey))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func TestNewTokenServiceRejectsWeakKeys(t *testing.T) {
+	for name, key := range map[string]string{
+		"sample": sampleSigningKey,
+		"short":  strings.Repeat("x", minSigningKeyLength-1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewTokenService(newMemoryTokenStore(), TokenConfig{
+				ActiveKeyID: "k1",
+				Keys:        map[string]string{"k1": key},
+			})
+			if err == nil {
+				t.Fatal("NewTokenService accepted a weak key")
+			}
+		})
+	}
+}
+
+func TestParseRejectsForgedClaims(t *testing.T) {
+	tokens := newTestTokenService(t, "pms")
+	now := time.Now()
+	valid := TokenClaims{
+		Type: accessTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Issuer:    "pms",
+			Subject:   "1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+
+	if _, err := tokens.ValidateAccessToken(signTestToken(t, "k1", valid)); err != nil {
+		t.Fatalf("baseline token rejected: %v", err)
+	}
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	noID := valid
+	noID.ID = ""
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", signTestToken(t, "k2", valid)},
+		{"missing kid", signTestToken(t, "", valid)},
+		{"wrong issuer", signTestToken(t, "k1", wrongIssuer)},
+		{"missing exp", signTestToken(t, "k1", noExpiry)},
+		{"missing jti", signTestToken(t, "k1", noID)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.ValidateAccessToken(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ValidateAccessToken error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	tokens := newTestTokenService(t, "pms")
+	pair, err := tokens.IssueTokens(&models.User{ID: 1})
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	if _, err := tokens.Refresh(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh with access token error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := tokens.ValidateAccessToken(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateAccessToken with refresh token error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokensFromAnotherIssuerAreRejected(t *testing.T) {
+	other := newTestTokenService(t, "other-issuer")
+	pair, err := other.IssueTokens(&models.User{ID: 1})
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	tokens := newTestTokenService(t, "pms")
+	if _, err := tokens.ValidateAccessToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateAccessToken error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshTokensAreSingleUse(t *testing.T) {
+	tokens := newTestTokenService(t, "pms")
+	pair, err := tokens.IssueTokens(&models.User{ID: 1})
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	rotated, err := tokens.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("first Refresh: %v", err)
+	}
+	if _, err := tokens.Refresh(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Refresh error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := tokens.Refresh(rotated.RefreshToken); err != nil {
+		t.Errorf("Refresh with rotated token: %v", err)
+	}
+}
+
+func TestRevokeRefreshTokenOfAnotherUser(t *testing.T) {
+	tokens := newTestTokenService(t, "pms")
+	pair, err := tokens.IssueTokens(&models.User{ID: 1})
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	if err := tokens.RevokeRefreshToken(pair.RefreshToken, 2); !errors.Is(err, ErrTokenNotOwned) {
+		t.Fatalf("RevokeRefreshToken error = %v, want ErrTokenNotOwned", err)
+	}
+	// The owner's token must survive the refused revocation
+	if _, err := tokens.Refresh(pair.RefreshToken); err != nil {
+		t.Errorf("Refresh after refused revocation: %v", err)
+	}
+}
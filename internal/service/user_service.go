@@ -1,10 +1,29 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"product-management-system/internal/models"
 	"product-management-system/internal/repository"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// ErrEmailTaken is returned when registering an email that already has an account
+var ErrEmailTaken = errors.New("email already registered")
+
+// ErrInvalidCredentials is returned when an email/password pair does not match a user
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// ErrPasswordNotSet is returned for users adopted from the legacy schema, which have no password yet.
+// It wraps ErrInvalidCredentials, so callers that do not distinguish it still reject the login.
+var ErrPasswordNotSet = fmt.Errorf("%w: no password set", ErrInvalidCredentials)
+
+// dummyPasswordHash is compared against when no user matches, so unknown emails cost the same as wrong passwords
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
 // UserService handles business logic for users
 type UserService struct {
 	Repo repository.UserRepository
@@ -15,12 +34,64 @@ func NewUserService(repo repository.UserRepository) *UserService {
 	return &UserService{Repo: repo}
 }
 
-// RegisterUser registers a new user
+// RegisterUser registers a new user, replacing the plaintext Password with its bcrypt hash
 func (s *UserService) RegisterUser(user *models.User) error {
-	return s.Repo.CreateUser(user)
+	user.Email = normalizeEmail(user.Email)
+
+	if _, err := s.Repo.GetUserByEmail(user.Email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hash)
+
+	// The unique index still guards against concurrent registrations
+	if err := s.Repo.CreateUser(user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate verifies an email/password pair and returns the matching user
+func (s *UserService) Authenticate(email, password string) (*models.User, error) {
+	user, err := s.Repo.GetUserByEmail(normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if user.Password == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrPasswordNotSet
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
 }
 
 // GetUserByEmail retrieves a user by their email
 func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
-	return s.Repo.GetUserByEmail(email)
+	return s.Repo.GetUserByEmail(normalizeEmail(email))
+}
+
+// GetUserByID retrieves a user by their ID
+func (s *UserService) GetUserByID(id uint) (*models.User, error) {
+	return s.Repo.GetUserByID(id)
+}
+
+// normalizeEmail makes email comparisons case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -0,0 +1,43 @@
+package shared
+
+// FacetName selects a facet the product list can count
+type FacetName string
+
+const (
+	FacetPrice    FacetName = "price"
+	FacetCategory FacetName = "category"
+	FacetTag      FacetName = "tag"
+	FacetSeller   FacetName = "seller"
+)
+
+// FacetNames lists every facet in the order they are reported
+var FacetNames = []FacetName{FacetPrice, FacetCategory, FacetTag, FacetSeller}
+
+// ProductFacets holds the requested facet counts of a product listing.
+// Each facet is counted with every filter except its own, so a sidebar shows what choosing another value would yield.
+// Facets that were not requested or have no values are omitted.
+type ProductFacets struct {
+	Price    []PriceBucket `json:"price,omitempty"`
+	Category []FacetCount  `json:"category,omitempty"`
+	Tag      []FacetCount  `json:"tag,omitempty"`
+	Seller   []SellerCount `json:"seller,omitempty"`
+}
+
+// PriceBucket counts products priced from Min up to but excluding Max; the last bucket has no Max
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// FacetCount counts the products with one category or tag
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SellerCount counts the products of one seller
+type SellerCount struct {
+	UserID uint  `json:"user_id"`
+	Count  int64 `json:"count"`
+}
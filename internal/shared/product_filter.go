@@ -1,9 +1,154 @@
 package shared
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"product-management-system/internal/models"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ProductSort is a key products can be listed by
+type ProductSort string
+
+const (
+	SortByCreatedAt ProductSort = "created_at"
+	SortByPrice     ProductSort = "price"
+	SortByName      ProductSort = "name"
+	// SortByRelevance orders by full-text rank and requires a search query
+	SortByRelevance ProductSort = "relevance"
+)
+
+// MaxSearchQueryLength bounds the text accepted as a search query
+const MaxSearchQueryLength = 200
+
+// ErrInvalidCursor is returned for page cursors that were not issued by the list endpoint
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 // ProductFilter represents filtering criteria for listing products
 type ProductFilter struct {
-    UserID      uint
-    MinPrice    float64
-    MaxPrice    float64
-    ProductName string
+	UserID      uint
+	MinPrice    float64
+	MaxPrice    float64
+	ProductName string
+	// Query is free text matched against product names and descriptions
+	Query    string
+	Category string
+	// Tag keeps products carrying this tag
+	Tag string
+
+	// Sort orders the page; ties are broken by ID so consecutive pages never overlap or skip rows
+	Sort       ProductSort
+	Descending bool
+	Limit      int
+	// Offset skips rows and cannot be combined with After
+	Offset int
+	// After continues from the last product of a previous page
+	After *ProductCursor
+	// IncludeTotal also counts every matching row, which costs a second query
+	IncludeTotal bool
+	// Facets are counted alongside the page
+	Facets []FacetName
+}
+
+// ProductPage is one page of a product listing
+type ProductPage struct {
+	Products []ProductListItem `json:"products"`
+	Limit    int               `json:"limit"`
+	// NextCursor is empty on the last page
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      *int64         `json:"total,omitempty"`
+	Facets     *ProductFacets `json:"facets,omitempty"`
+}
+
+// ProductListItem is a listed product, with how it matched when the listing is a search
+type ProductListItem struct {
+	models.Product
+	Search *SearchMatch `json:"search,omitempty"`
+}
+
+// SearchMatch describes how a product matched a search query.
+// Highlights are HTML-escaped text with matched words wrapped in <mark> tags.
+type SearchMatch struct {
+	Rank          float64 `json:"rank"`
+	NameHighlight string  `json:"name_highlight"`
+	Snippet       string  `json:"snippet"`
+}
+
+// SearchTerms splits a search query into the words it is matched by, dropping punctuation and operators
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ProductCursor is the keyset position after a product: its sort value and ID.
+// The sort and direction are kept so a cursor cannot be replayed against a different ordering.
+type ProductCursor struct {
+	Sort       ProductSort `json:"s"`
+	Descending bool        `json:"d,omitempty"`
+	Price      float64     `json:"p,omitempty"`
+	Name       string      `json:"n,omitempty"`
+	CreatedAt  time.Time   `json:"c,omitempty"`
+	Rank       float64     `json:"r,omitempty"`
+	ID         uint        `json:"i"`
+}
+
+// NewProductCursor returns the position right after item in the given ordering
+func NewProductCursor(item ProductListItem, sort ProductSort, descending bool) ProductCursor {
+	product := item.Product
+	cursor := ProductCursor{Sort: sort, Descending: descending, ID: product.ID}
+	switch sort {
+	case SortByRelevance:
+		if item.Search != nil {
+			cursor.Rank = item.Search.Rank
+		}
+	case SortByPrice:
+		cursor.Price = product.ProductPrice
+	case SortByName:
+		cursor.Name = product.ProductName
+	default:
+		cursor.CreatedAt = product.CreatedAt
+	}
+	return cursor
+}
+
+// Value returns the sort value the cursor resumes after
+func (c ProductCursor) Value() interface{} {
+	switch c.Sort {
+	case SortByRelevance:
+		return c.Rank
+	case SortByPrice:
+		return c.Price
+	case SortByName:
+		return c.Name
+	default:
+		return c.CreatedAt
+	}
+}
+
+// Encode returns the opaque form handed to clients
+func (c ProductCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeProductCursor parses a cursor produced by Encode
+func DecodeProductCursor(encoded string) (*ProductCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor ProductCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	switch cursor.Sort {
+	case SortByCreatedAt, SortByPrice, SortByName, SortByRelevance:
+		return &cursor, nil
+	default:
+		return nil, ErrInvalidCursor
+	}
 }
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"product-management-system/config"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps objects on local disk, for development and tests.
+// Objects are served as static files, so every URL is public.
+type LocalStorage struct {
+	rootDir       string
+	publicBaseURL string
+}
+
+// NewLocalStorage creates a LocalStorage rooted at cfg.RootDir
+func NewLocalStorage(cfg config.LocalConfig) (*LocalStorage, error) {
+	if cfg.RootDir == "" {
+		return nil, errors.New("local storage root_dir is required")
+	}
+	if err := os.MkdirAll(cfg.RootDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalStorage{
+		rootDir:       cfg.RootDir,
+		publicBaseURL: strings.TrimRight(cfg.PublicBaseURL, "/"),
+	}, nil
+}
+
+// RootDir returns the directory objects are stored in
+func (s *LocalStorage) RootDir() string {
+	return s.rootDir
+}
+
+// Put writes an object atomically via a temporary file
+func (s *LocalStorage) Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+// Get opens an object for reading
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes an object
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether an object is stored under key
+func (s *LocalStorage) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object: %w", err)
+	}
+	return true, nil
+}
+
+// URL returns the public URL of an object
+func (s *LocalStorage) URL(key string) string {
+	return s.publicBaseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+// PresignedURL returns the public URL, since local objects have no access control
+func (s *LocalStorage) PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.URL(key), nil
+}
+
+// path maps a key to a file path, rejecting keys that would escape the root directory
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + filepath.FromSlash(key))
+	if cleaned == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.rootDir, cleaned), nil
+}
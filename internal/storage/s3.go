@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"product-management-system/config"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage stores objects in an S3-compatible bucket
+type S3Storage struct {
+	client        *minio.Client
+	bucket        string
+	publicBaseURL string
+}
+
+// NewS3Storage creates an S3Storage, falling back to AWS_* or MINIO_* environment credentials
+func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+
+	endpoint := cfg.Endpoint
+	secure := cfg.UseSSL
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+		secure = true
+	}
+
+	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	if cfg.AccessKeyID == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+		})
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	if cfg.CreateBucket {
+		ctx := context.Background()
+		exists, err := client.BucketExists(ctx, cfg.Bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check s3 bucket: %w", err)
+		}
+		if !exists {
+			if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+				return nil, fmt.Errorf("failed to create s3 bucket: %w", err)
+			}
+		}
+	}
+
+	// Without a CDN in front, objects are addressed path-style on the endpoint
+	publicBaseURL := strings.TrimRight(cfg.PublicBaseURL, "/")
+	if publicBaseURL == "" {
+		publicBaseURL = fmt.Sprintf("%s/%s", strings.TrimRight(client.EndpointURL().String(), "/"), cfg.Bucket)
+	}
+
+	return &S3Storage{
+		client:        client,
+		bucket:        cfg.Bucket,
+		publicBaseURL: publicBaseURL,
+	}, nil
+}
+
+// Put uploads an object
+func (s *S3Storage) Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, data, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+// Get opens an object for reading
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// GetObject is lazy; Stat surfaces a missing key before the caller starts reading
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return object, nil
+}
+
+// Delete removes an object
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether an object is stored under key
+func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if isNoSuchKey(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object: %w", err)
+	}
+	return true, nil
+}
+
+// URL returns the public URL of an object
+func (s *S3Storage) URL(key string) string {
+	return s.publicBaseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+// PresignedURL returns a signed GET URL valid for expiry
+func (s *S3Storage) PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	signed, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object URL: %w", err)
+	}
+	return signed.String(), nil
+}
+
+// isNoSuchKey reports whether err is S3's missing-object error
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
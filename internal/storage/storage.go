@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"product-management-system/config"
+	"strings"
+	"time"
+)
+
+// Supported storage backends
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores binary objects such as processed product images
+type Storage interface {
+	// Put uploads data under key, replacing any existing object
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error
+	// Get opens an object for reading; callers must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether an object is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL returns the public URL of an object
+	URL(key string) string
+	// PresignedURL returns a time-limited URL granting read access to an object
+	PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// New creates the storage backend selected by backend
+func New(backend string, local config.LocalConfig, s3 config.S3Config) (Storage, error) {
+	switch backend {
+	case BackendLocal, "":
+		return NewLocalStorage(local)
+	case BackendS3:
+		return NewS3Storage(s3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// KeyFromURL returns the key of an object in store addressed by its public URL.
+// It reports false for URLs that point anywhere else.
+func KeyFromURL(store Storage, rawURL string) (string, bool) {
+	key, ok := strings.CutPrefix(rawURL, store.URL(""))
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
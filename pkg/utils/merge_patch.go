@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MergePatch applies a JSON merge patch (RFC 7396) to the target document
+func MergePatch(target, patch []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	var targetValue interface{}
+	if len(target) > 0 {
+		if err := json.Unmarshal(target, &targetValue); err != nil {
+			return nil, fmt.Errorf("invalid merge target: %w", err)
+		}
+	}
+
+	return json.Marshal(mergeValue(targetValue, patchValue))
+}
+
+// mergeValue recursively merges patch into target, removing null members
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
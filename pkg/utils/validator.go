@@ -2,9 +2,23 @@ package utils
 
 import (
 	"errors"
+	"net/mail"
 	"product-management-system/internal/models"
 )
 
+// MinPasswordLength is the shortest password accepted at registration
+const MinPasswordLength = 8
+
+// bcrypt ignores everything past 72 bytes, so longer passwords are rejected
+const maxPasswordLength = 72
+
+// Limits on the catalog fields products are filtered and faceted by
+const (
+	maxCategoryLength = 100
+	maxTags           = 20
+	maxTagLength      = 50
+)
+
 func ValidateProduct(product models.Product) error {
 	if product.ProductName == "" {
 		return errors.New("product name is required")
@@ -12,10 +26,43 @@ func ValidateProduct(product models.Product) error {
 	if product.ProductPrice <= 0 {
 		return errors.New("product price must be positive")
 	}
+	if len(product.Category) > maxCategoryLength {
+		return errors.New("category must be at most 100 characters")
+	}
+	if len(product.Tags) > maxTags {
+		return errors.New("a product can have at most 20 tags")
+	}
+	for _, tag := range product.Tags {
+		if tag == "" || len(tag) > maxTagLength {
+			return errors.New("tags must be between 1 and 50 characters")
+		}
+	}
 	return nil
 }
 
 func ValidateProductUpdate(product models.Product) error {
-	// Similar validation, but can be less strict
+	// Updates apply to an existing row, so the identity must already be set
+	if product.ID == 0 {
+		return errors.New("product ID is required")
+	}
+	if product.UserID == 0 {
+		return errors.New("product owner is required")
+	}
+	return ValidateProduct(product)
+}
+
+func ValidateUserRegistration(user models.User) error {
+	if user.Email == "" {
+		return errors.New("email is required")
+	}
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		return errors.New("email is invalid")
+	}
+	if len(user.Password) < MinPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(user.Password) > maxPasswordLength {
+		return errors.New("password must be at most 72 bytes")
+	}
 	return nil
-}
\ No newline at end of file
+}